@@ -0,0 +1,392 @@
+package console
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// ArgType is the type of a positional argument.
+type ArgType string
+
+// Positional argument types.
+const (
+	ArgString   ArgType = "string"
+	ArgInt      ArgType = "int"
+	ArgFloat    ArgType = "float"
+	ArgDuration ArgType = "duration"
+	ArgEnum     ArgType = "enum"
+	ArgPath     ArgType = "path"
+	ArgDir      ArgType = "dir"
+	ArgVariable ArgType = "variable"
+)
+
+// Arg describes a positional argument of a command. Optional args must follow the required ones
+// and only the last arg can be variadic, otherwise adding the command panics.
+type Arg struct {
+	Name        string
+	Description string
+
+	// Type defaults to ArgString. Enum args accept one of Values and variable args the name of a
+	// variable which is set. Path and dir args are completed from the file system and a leading ~
+	// is expanded. Extensions restricts the files suggested for path args.
+	Type       ArgType
+	Values     []string
+	Extensions []string
+
+	Optional bool
+	Variadic bool
+
+	// Suggestions overrides the suggestions derived from the type.
+	Suggestions func(env *Environment, args []string) []string
+}
+
+// usage returns the arg as shown in the usage line, e.g. `<symbol>` or `[leverage]`.
+func (arg Arg) usage() string {
+	name := arg.Name
+	if arg.Variadic {
+		name += "..."
+	}
+	if arg.Optional {
+		return "[" + name + "]"
+	}
+	return "<" + name + ">"
+}
+
+// parse converts an arg value to its type.
+func (arg Arg) parse(env *Environment, value string) (interface{}, error) {
+	switch arg.Type {
+	case "", ArgString:
+		return value, nil
+	case ArgPath, ArgDir:
+		return ExpandHome(value), nil
+	case ArgInt, ArgFloat, ArgDuration:
+		converted, err := ParseValue(string(arg.Type), value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for <%s>: expected %s", value, arg.Name, arg.Type)
+		}
+		return converted, nil
+	case ArgEnum:
+		for _, allowed := range arg.Values {
+			if value == allowed {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid value %q for <%s>: expected one of %s", value, arg.Name, strings.Join(arg.Values, ", "))
+	case ArgVariable:
+		if _, ok := env.Lookup(value); !ok {
+			return nil, fmt.Errorf("invalid value %q for <%s>: %s is not set", value, arg.Name, value)
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("unknown type %q for <%s>", arg.Type, arg.Name)
+}
+
+// suggestions returns the suggestions derived from the arg type.
+func (arg Arg) suggestions(env *Environment, args []string) []string {
+	if arg.Suggestions != nil {
+		return arg.Suggestions(env, args)
+	}
+
+	switch arg.Type {
+	case ArgEnum:
+		return arg.Values
+	case ArgVariable:
+		return env.Keys()
+	}
+	return nil
+}
+
+// validateArgSpec checks the order of the optional and variadic args.
+func validateArgSpec(args []Arg) error {
+	optional := ""
+	for index, arg := range args {
+		if arg.Variadic && index < len(args)-1 {
+			return fmt.Errorf("variadic argument <%s> must be the last argument", arg.Name)
+		}
+		if arg.Optional && optional == "" {
+			optional = arg.Name
+		} else if !arg.Optional && optional != "" {
+			return fmt.Errorf("required argument <%s> follows optional argument [%s]", arg.Name, optional)
+		}
+	}
+	return nil
+}
+
+// parseArgs validates the positional args against the arg spec and stores the converted values.
+func (cmd *Command) parseArgs(env *Environment, args []string) error {
+	cmd.values = map[string]interface{}{}
+	if len(cmd.Args) == 0 {
+		return nil
+	}
+
+	last := cmd.Args[len(cmd.Args)-1]
+	if len(args) > len(cmd.Args) && !last.Variadic {
+		return fmt.Errorf("accepts at most %d args, received %d", len(cmd.Args), len(args))
+	}
+
+	for index, arg := range cmd.Args {
+		if index >= len(args) {
+			if !arg.Optional {
+				return fmt.Errorf("missing argument <%s>", arg.Name)
+			}
+			continue
+		}
+
+		if !arg.Variadic {
+			value, err := arg.parse(env, args[index])
+			if err != nil {
+				return err
+			}
+			cmd.values[arg.Name] = value
+			continue
+		}
+
+		var values []interface{}
+		for _, input := range args[index:] {
+			value, err := arg.parse(env, input)
+			if err != nil {
+				return err
+			}
+			values = append(values, value)
+		}
+		cmd.values[arg.Name] = values
+	}
+	return nil
+}
+
+// argAt returns the arg spec for the position. The last arg is used for all positions after it
+// if it is variadic.
+func (cmd *Command) argAt(position int) (Arg, bool) {
+	if len(cmd.Args) == 0 {
+		return Arg{}, false
+	}
+	if position < len(cmd.Args) {
+		return cmd.Args[position], true
+	}
+	if last := cmd.Args[len(cmd.Args)-1]; last.Variadic {
+		return last, true
+	}
+	return Arg{}, false
+}
+
+// ArgValue returns the converted value of a positional arg. Variadic args are returned as
+// []interface{}. Returns nil if the arg was not given.
+func (cmd *Command) ArgValue(name string) interface{} {
+	return cmd.values[name]
+}
+
+// GetArgString returns the value of a string, enum, path or variable arg.
+func (cmd *Command) GetArgString(name string) (string, error) {
+	value, err := cmd.argValue(name)
+	if err != nil || value == nil {
+		return "", err
+	}
+	if str, ok := value.(string); ok {
+		return str, nil
+	}
+	return "", fmt.Errorf("argument <%s> is not a string", name)
+}
+
+// GetArgInt returns the value of an int arg.
+func (cmd *Command) GetArgInt(name string) (int, error) {
+	value, err := cmd.argValue(name)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	if n, ok := value.(int); ok {
+		return n, nil
+	}
+	return 0, fmt.Errorf("argument <%s> is not an int", name)
+}
+
+// GetArgFloat returns the value of a float arg.
+func (cmd *Command) GetArgFloat(name string) (float64, error) {
+	value, err := cmd.argValue(name)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	if f, ok := value.(float64); ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("argument <%s> is not a float", name)
+}
+
+// GetArgDuration returns the value of a duration arg.
+func (cmd *Command) GetArgDuration(name string) (time.Duration, error) {
+	value, err := cmd.argValue(name)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	if d, ok := value.(time.Duration); ok {
+		return d, nil
+	}
+	return 0, fmt.Errorf("argument <%s> is not a duration", name)
+}
+
+// GetArgList returns the values of a variadic arg.
+func (cmd *Command) GetArgList(name string) ([]interface{}, error) {
+	value, err := cmd.argValue(name)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	if list, ok := value.([]interface{}); ok {
+		return list, nil
+	}
+	return nil, fmt.Errorf("argument <%s> is not variadic", name)
+}
+
+// argValue returns the value of an arg defined in the arg spec.
+func (cmd *Command) argValue(name string) (interface{}, error) {
+	for _, arg := range cmd.Args {
+		if arg.Name == name {
+			return cmd.values[name], nil
+		}
+	}
+	return nil, fmt.Errorf("argument <%s> is not defined", name)
+}
+
+// argsUsage returns the usage line of the arg spec, e.g. `risk [flags] <symbol> <amount> [leverage]`.
+func (cmd *Command) argsUsage() string {
+	parts := []string{cmd.CommandPath()}
+	if cmd.hasVisibleFlags() {
+		parts = append(parts, "[flags]")
+	}
+	for _, arg := range cmd.Args {
+		parts = append(parts, arg.usage())
+	}
+	return strings.Join(parts, " ")
+}
+
+// argsDescription lists the args with their description and type.
+func (cmd *Command) argsDescription() string {
+	var names []string
+	for _, arg := range cmd.Args {
+		names = append(names, arg.usage())
+	}
+
+	var buf bytes.Buffer
+	maxLen := getMaxLength(names)
+	for index, arg := range cmd.Args {
+		description := arg.Description
+		switch arg.Type {
+		case "", ArgString:
+		case ArgEnum:
+			description += " (one of " + strings.Join(arg.Values, ", ") + ")"
+		default:
+			description += " (" + string(arg.Type) + ")"
+		}
+		fmt.Fprintf(&buf, "  %s    %s\n", padRight(names[index], " ", maxLen), strings.TrimSpace(description))
+	}
+	return buf.String()
+}
+
+func (cmd *Command) hasVisibleFlags() bool {
+	var visible bool
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		visible = visible || !flag.Hidden
+	})
+	return visible
+}
+
+// positionalArgs returns the words which are not flags or flag values. All words after `--` are
+// positional.
+func positionalArgs(cmd *Command, words []string) []string {
+	var positional []string
+	for index := 0; index < len(words); index++ {
+		word := words[index]
+		if word == "--" {
+			return append(positional, words[index+1:]...)
+		} else if !strings.HasPrefix(word, "-") || word == "-" {
+			positional = append(positional, word)
+			continue
+		}
+
+		// Skip the value of a flag given as a separate word
+		if valueFlag(cmd, word) != nil {
+			index++
+		}
+	}
+	return positional
+}
+
+// completingFlag returns the flag whose value is being typed in word and the part of the word
+// before the value. The value is either given in the word, e.g. `--config=`, or the word follows
+// a flag which takes a value, e.g. `--config `.
+func completingFlag(cmd *Command, words []string, word string) (*pflag.Flag, string, bool) {
+	for _, completed := range words {
+		if completed == "--" {
+			return nil, "", false
+		}
+	}
+
+	if strings.HasPrefix(word, "-") {
+		index := strings.Index(word, "=")
+		if index < 0 {
+			return nil, "", false
+		}
+		if flag := lookupFlag(cmd, word[:index]); flag != nil {
+			return flag, word[:index+1], true
+		}
+		return nil, "", false
+	}
+
+	if len(words) == 0 {
+		return nil, "", false
+	}
+	if flag := valueFlag(cmd, words[len(words)-1]); flag != nil {
+		return flag, "", true
+	}
+	return nil, "", false
+}
+
+// valueFlag returns the flag which takes the next word as its value, e.g. `--config` or `-vf` where
+// f is the last of the combined shorthands. Returns nil if the word is not such a flag.
+func valueFlag(cmd *Command, word string) *pflag.Flag {
+	if !strings.HasPrefix(word, "-") || word == "-" || word == "--" || strings.Contains(word, "=") {
+		return nil
+	}
+
+	if strings.HasPrefix(word, "--") {
+		if flag := lookupFlag(cmd, word); flag != nil && flag.NoOptDefVal == "" {
+			return flag
+		}
+		return nil
+	}
+
+	for index := 1; index < len(word); index++ {
+		flag := cmd.Flags().ShorthandLookup(word[index : index+1])
+		if flag == nil && index == 1 {
+			flag = outputFlag(cmd, "", word[1:2])
+		}
+		if flag == nil {
+			return nil
+		} else if flag.NoOptDefVal == "" {
+			// The rest of the word is the value unless the shorthand is the last one
+			if index == len(word)-1 {
+				return flag
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// lookupFlag returns the flag named by `--name` or `-n`, including the global output flag.
+func lookupFlag(cmd *Command, word string) *pflag.Flag {
+	if strings.HasPrefix(word, "--") {
+		if flag := cmd.Flags().Lookup(word[2:]); flag != nil {
+			return flag
+		}
+		return outputFlag(cmd, word[2:], "")
+	} else if len(word) == 2 && word[0] == '-' {
+		if flag := cmd.Flags().ShorthandLookup(word[1:]); flag != nil {
+			return flag
+		}
+		return outputFlag(cmd, "", word[1:])
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package console
+
+import "testing"
+
+func TestValueFlag(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"-f", "file"},
+		{"--file", "file"},
+		{"-vf", "file"},
+		{"-fx", ""},
+		{"-v", ""},
+		{"--verbose", ""},
+		{"--file=x", ""},
+		{"--", ""},
+		{"-", ""},
+		{"arg", ""},
+		{"-o", "output"},
+		{"-é", ""},
+		{"-vé", ""},
+		{"-éf", ""},
+	}
+
+	cmd := newFlagCommand()
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			var got string
+			if flag := valueFlag(cmd, tt.word); flag != nil {
+				got = flag.Name
+			}
+			if got != tt.want {
+				t.Errorf("valueFlag(%q) = %q, want %q", tt.word, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package console
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/c-bata/go-prompt"
 	"github.com/gookit/color"
@@ -21,6 +23,11 @@ type ColorScheme struct {
 	SelectedSuggestionTextColor  prompt.Color
 	SelectedDescriptionBGColor   prompt.Color
 	SelectedDescriptionTextColor prompt.Color
+
+	// Output colors of the environment. The colors of the default scheme are used if nil.
+	InfoLabelColor color.Style
+	ErrorColor     color.Style
+	WarningColor   color.Style
 }
 
 // DefaultColorScheme is the default color scheme for clix.
@@ -37,9 +44,43 @@ var DefaultColorScheme = &ColorScheme{
 	SelectedSuggestionTextColor:  prompt.DarkGray,
 	SelectedDescriptionBGColor:   prompt.DarkGray,
 	SelectedDescriptionTextColor: prompt.White,
+	InfoLabelColor:               color.Style{color.FgLightGreen},
+	ErrorColor:                   color.Error.Style,
+	WarningColor:                 color.Warn.Style,
+}
+
+// colorOptions returns the prompt options setting the colors of the scheme.
+func colorOptions(scheme *ColorScheme) []prompt.Option {
+	return []prompt.Option{
+		prompt.OptionScrollbarThumbColor(scheme.ScrollbarThumbColor),
+		prompt.OptionScrollbarBGColor(scheme.ScrollbarBGColor),
+		prompt.OptionPrefixTextColor(scheme.PrefixTextColor),
+		prompt.OptionInputTextColor(scheme.InputTextColor),
+		prompt.OptionDescriptionBGColor(scheme.DescriptionBGColor),
+		prompt.OptionDescriptionTextColor(scheme.DescriptionTextColor),
+		prompt.OptionSuggestionBGColor(scheme.SuggestionBGColor),
+		prompt.OptionSuggestionTextColor(scheme.SuggestionTextColor),
+		prompt.OptionSelectedSuggestionBGColor(scheme.SelectedSuggestionBGColor),
+		prompt.OptionSelectedSuggestionTextColor(scheme.SelectedSuggestionTextColor),
+		prompt.OptionSelectedDescriptionBGColor(scheme.SelectedDescriptionBGColor),
+		prompt.OptionSelectedDescriptionTextColor(scheme.SelectedDescriptionTextColor),
+	}
 }
 
-// PrintInfo prints info with a green label
+// outputStyle returns the style or the style of the default scheme if it is not set.
+func outputStyle(style color.Style, fallback color.Style) color.Style {
+	if style == nil {
+		return fallback
+	}
+	return style
+}
+
+// PrintInfo prints info with a label in the info color of the default color scheme. Commands should
+// use Environment.PrintInfo, which uses the color scheme of the console and the output stream.
 func PrintInfo(label string, format string, value ...interface{}) {
-	fmt.Printf("%s: %s\n", color.LightGreen.Render(label), fmt.Sprintf(format, value...))
+	fprintInfo(os.Stdout, outputStyle(DefaultColorScheme.InfoLabelColor, color.Style{color.FgLightGreen}), label, format, value...)
+}
+
+func fprintInfo(w io.Writer, style color.Style, label string, format string, value ...interface{}) {
+	fmt.Fprintf(w, "%s: %s\n", style.Render(label), fmt.Sprintf(format, value...))
 }
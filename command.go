@@ -2,9 +2,14 @@ package console
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/pflag"
 )
@@ -17,14 +22,48 @@ type Command struct {
 	Aliases          []string
 	RequiredFlags    []string
 	ValidateArgs     ValidationFunc
+	FlagValidators   map[string]ValidationFunc
 	Run              func(env *Environment, cmd *Command, args []string) error
 	Suggestions      func(env *Environment, args []string) []string
 	EagerSuggestions bool
 	IsBuiltIn        bool
 	ShouldPropagate  bool
 
-	flags         *pflag.FlagSet
-	requiredFlags []string
+	// RichSuggestions returns suggestions with descriptions and groups. It is used along with
+	// Suggestions.
+	RichSuggestions func(env *Environment, args []string) []Suggestion
+
+	// Completer provides suggestions which are slow to compute, e.g. requested from an API. See
+	// NewCachedCompletion.
+	Completer CompletionProvider
+
+	// Matcher overrides the matcher of the console when completing the args of the command.
+	Matcher MatchFunc
+
+	// Args describes the positional args. The args are validated and converted before the command
+	// runs, see ArgValue.
+	Args []Arg
+
+	// Flag groups are validated before the command runs. Bound flags set from env vars count as given
+	// except for MutuallyExclusive, which only checks the flags given on the command line.
+	// FlagImplies maps a flag to the flags required when it is given.
+	RequiredTogether  [][]string
+	MutuallyExclusive [][]string
+	OneRequired       [][]string
+	FlagImplies       map[string][]string
+
+	// Timeout cancels the context of the environment if the command runs longer than the duration.
+	Timeout time.Duration
+
+	flags    *pflag.FlagSet
+	commands map[string]*Command
+	parent   *Command
+	bound    map[string]bool
+	values   map[string]interface{}
+
+	// running is set while an execution uses the flags of the command. Executions which overlap
+	// use copies of the flags.
+	running bool
 }
 
 // Flags returns the pflag.FlagSet. It will initialize the FlagSet if nil.
@@ -35,66 +74,400 @@ func (cmd *Command) Flags() *pflag.FlagSet {
 	return cmd.flags
 }
 
+// AddCommand adds a sub-command. Sub-commands are executed with `<command> <sub-command> [args...]`.
+func (cmd *Command) AddCommand(sub *Command) {
+	if cmd.commands == nil {
+		cmd.commands = map[string]*Command{}
+	}
+	initCommand(sub)
+	sub.parent = cmd
+
+	cmd.commands[sub.Use] = sub
+	for _, alias := range sub.Aliases {
+		cmd.commands[alias] = sub
+	}
+}
+
+// Commands returns the sub-commands including aliases.
+func (cmd *Command) Commands() map[string]*Command {
+	return cmd.commands
+}
+
+// HasSubCommands returns true if the command has sub-commands.
+func (cmd *Command) HasSubCommands() bool {
+	return len(cmd.commands) > 0
+}
+
+// Parent returns the parent command. Returns nil if the command is not a sub-command.
+func (cmd *Command) Parent() *Command {
+	return cmd.parent
+}
+
+// CommandPath returns the full name of the command including its parents, e.g. `account list`.
+func (cmd *Command) CommandPath() string {
+	if cmd.parent == nil {
+		return cmd.Use
+	}
+	return cmd.parent.CommandPath() + " " + cmd.Use
+}
+
+// AvailableCommands returns a sorted list of sub-command names without aliases.
+func (cmd *Command) AvailableCommands() []string {
+	var commands []string
+	for name, sub := range cmd.commands {
+		if name == sub.Use {
+			commands = append(commands, name)
+		}
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// findCommand returns the deepest sub-command named by the leading args and the number of args
+// naming it.
+func (cmd *Command) findCommand(args []string) (*Command, int) {
+	found, n := cmd, 0
+	for n < len(args) {
+		sub, ok := found.commands[args[n]]
+		if !ok {
+			break
+		}
+		found = sub
+		n++
+	}
+	return found, n
+}
+
+// initCommand sets the default suggestions and adds the help flag. It panics if the arg
+// spec is invalid, like pflag does for invalid flags.
+func initCommand(cmd *Command) {
+	if err := validateArgSpec(cmd.Args); err != nil {
+		panic(fmt.Sprintf("invalid args of '%s' command: %v", cmd.Use, err))
+	}
+
+	if cmd.Suggestions == nil {
+		cmd.Suggestions = func(*Environment, []string) []string { return nil }
+	}
+
+	// Add help flag
+	helpFlag := cmd.Flags().Lookup("help")
+	if helpFlag == nil {
+		cmd.Flags().BoolP("help", "h", false, "Prints this help")
+		cmd.Flags().Lookup("help").Hidden = true
+	}
+}
+
 // Execute executes the command with the given args. Flags are reset before execution.
+//
+// A command can run several times at once, e.g. as several jobs. Executions which overlap with a
+// running one parse the args into a copy of the flags, which Run receives as its command. Flags
+// bound to variables, e.g. with StringVar, are only set by executions which do not overlap, so Run
+// should read the flags with the Get functions of the flag set.
 func (cmd *Command) Execute(env *Environment, args []string) error {
-	cmd.flags.Visit(func(f *pflag.Flag) { f.Changed = false })
+	flagsMu.Lock()
+	run := cmd
+	if cmd.running {
+		run = cmd.copy()
+	} else {
+		cmd.running = true
+		defer func() {
+			flagsMu.Lock()
+			cmd.running = false
+			flagsMu.Unlock()
+		}()
+	}
+	usage, err := run.parse(env, args)
+	flagsMu.Unlock()
+
+	if err != nil {
+		return err
+	} else if usage != "" {
+		fmt.Fprintln(env.Out, usage)
+		return nil
+	}
+	return run.execute(env)
+}
+
+// flagsMu guards the flags of the commands while they are parsed or copied.
+var flagsMu sync.Mutex
+
+// parse parses the args into the flags and the arg values. Returns the usage if help was requested.
+func (cmd *Command) parse(env *Environment, args []string) (string, error) {
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed || cmd.bound[flag.Name] {
+			resetFlag(flag)
+		}
+	})
+	cmd.bound = map[string]bool{}
 
 	// Parse flags
 	if err := cmd.Flags().Parse(args); err != nil {
-		return err
+		return "", err
 	}
 
 	helpFlag := cmd.Flags().Lookup("help")
 	if helpFlag != nil && helpFlag.Changed {
-		fmt.Println(cmd.Usage())
-		return nil
+		return cmd.usage(env), nil
+	}
+
+	// Fall back to the bound env vars
+	if err := cmd.applyBindings(env); err != nil {
+		return "", err
 	}
 
 	// Validate flags
-	if len(cmd.RequiredFlags) > 0 {
-		if err := cmd.validateRequiredFlags(); err != nil {
-			return err
-		}
+	if err := cmd.validateFlags(); err != nil {
+		return "", err
 	}
+	return "", cmd.parseArgs(env, cmd.Flags().Args())
+}
 
+func (cmd *Command) execute(env *Environment) error {
 	// Validate args
 	if cmd.ValidateArgs != nil {
-		if err := cmd.ValidateArgs(args); err != nil {
+		if err := cmd.ValidateArgs(cmd.Flags().Args()); err != nil {
+			var argErr *ArgError
+			if errors.As(err, &argErr) && argErr.Name == "" && argErr.Index < len(cmd.Args) {
+				argErr.Name = cmd.Args[argErr.Index].Name
+			}
 			return err
 		}
 	}
 
 	if cmd.Run != nil {
+		if cmd.Timeout > 0 {
+			ctx, cancel := context.WithTimeout(env.Context(), cmd.Timeout)
+			defer cancel()
+
+			parent := env.ctx
+			env.ctx = ctx
+			defer func() {
+				env.ctx = parent
+			}()
+		}
 		return cmd.Run(env, cmd, cmd.Flags().Args())
 	}
-	return errors.New("'" + cmd.Use + "' command has no run function")
+
+	// Commands which only group sub-commands print their usage
+	if cmd.HasSubCommands() {
+		if len(cmd.Flags().Args()) > 0 {
+			return fmt.Errorf("unknown command '%s' for '%s'", cmd.Flags().Arg(0), cmd.CommandPath())
+		}
+		fmt.Fprintln(env.Out, cmd.Usage())
+		return nil
+	}
+	return errors.New("'" + cmd.CommandPath() + "' command has no run function")
+}
+
+// copy returns a copy of the command with its own flags for an overlapping execution.
+func (cmd *Command) copy() *Command {
+	copied := *cmd
+	copied.running = true
+	copied.flags = copyFlags(cmd.Use, cmd.Flags())
+	copied.bound = nil
+	copied.values = nil
+	return &copied
+}
+
+// copyFlags returns a flag set with the flags of the given set and new values set to their
+// defaults. Values of types unknown to copyValue are shared.
+func copyFlags(name string, flags *pflag.FlagSet) *pflag.FlagSet {
+	copied := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	copied.SortFlags = flags.SortFlags
+	copied.SetNormalizeFunc(flags.GetNormalizeFunc())
+	copied.SetInterspersed(interspersed(flags))
+
+	flags.VisitAll(func(flag *pflag.Flag) {
+		value := *flag
+		value.Value = copyValue(flag)
+		value.Changed = false
+		copied.AddFlag(&value)
+	})
+	return copied
+}
+
+// interspersed returns whether the flag set parses flags after the args, see SetInterspersed.
+// The flag set has no getter for it.
+func interspersed(flags *pflag.FlagSet) bool {
+	field := reflect.ValueOf(flags).Elem().FieldByName("interspersed")
+	return !field.IsValid() || field.Bool()
+}
+
+// copyValue returns a new value of the type of the flag set to its default.
+func copyValue(flag *pflag.Flag) pflag.Value {
+	values := pflag.NewFlagSet("", pflag.ContinueOnError)
+	switch flag.Value.Type() {
+	case "bool":
+		values.Bool(flag.Name, false, "")
+	case "string":
+		values.String(flag.Name, "", "")
+	case "int":
+		values.Int(flag.Name, 0, "")
+	case "int64":
+		values.Int64(flag.Name, 0, "")
+	case "uint":
+		values.Uint(flag.Name, 0, "")
+	case "float64":
+		values.Float64(flag.Name, 0, "")
+	case "duration":
+		values.Duration(flag.Name, 0, "")
+	case "count":
+		values.Count(flag.Name, "")
+	case "stringSlice":
+		values.StringSlice(flag.Name, nil, "")
+	case "stringArray":
+		values.StringArray(flag.Name, nil, "")
+	case "intSlice":
+		values.IntSlice(flag.Name, nil, "")
+	default:
+		return flag.Value
+	}
+
+	copied := *values.Lookup(flag.Name)
+	copied.DefValue = flag.DefValue
+	resetFlag(&copied)
+	return copied.Value
+}
+
+// BindFlag binds a flag to an env var. If the flag is not given the value of the env var is used
+// instead, including variables local to the current scope.
+//
+// The binding is stored as a flag annotation instead of using viper's BindPFlag. Viper only sees the
+// global variables, so local variables could not be used, and a bound flag would override the env
+// var for everyone reading the Configuration while the flag is set.
+func (cmd *Command) BindFlag(name string, key string) error {
+	return cmd.Flags().SetAnnotation(name, ConfigKey, []string{key})
+}
+
+// flagBinding returns the env var a flag is bound to.
+func flagBinding(flag *pflag.Flag) (string, bool) {
+	if keys := flag.Annotations[ConfigKey]; len(keys) > 0 {
+		return keys[0], true
+	}
+	return "", false
+}
+
+// applyBindings sets the flags which were not given from their bound env vars.
+func (cmd *Command) applyBindings(env *Environment) error {
+	var err error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		key, ok := flagBinding(flag)
+		if !ok || flag.Changed || err != nil {
+			return
+		}
+
+		value, ok := env.Lookup(key)
+		if !ok {
+			return
+		}
+		if err = flag.Value.Set(FormatValue(value)); err != nil {
+			err = fmt.Errorf("invalid value for --%s from %s: %v", flag.Name, key, err)
+			return
+		}
+		cmd.bound[flag.Name] = true
+	})
+	return err
 }
 
-func (cmd *Command) validateRequiredFlags() error {
-	for index := 0; index < len(cmd.requiredFlags); index++ {
-		flag := cmd.Flags().Lookup(cmd.requiredFlags[index])
-		if flag != nil && flag.Changed {
-			return fmt.Errorf("%s flag is required", flag.Name)
+// resetFlag restores the default value of a flag set by a previous execution.
+func resetFlag(flag *pflag.Flag) {
+	if value, ok := flag.Value.(pflag.SliceValue); ok {
+		defaults := strings.Trim(flag.DefValue, "[]")
+		if defaults == "" {
+			value.Replace([]string{})
+		} else {
+			value.Replace(strings.Split(defaults, ","))
 		}
+	} else {
+		flag.Value.Set(flag.DefValue)
 	}
-	return nil
+	flag.Changed = false
 }
 
 // Usage returns the command usage.
 func (cmd *Command) Usage() string {
+	return cmd.usage(nil)
+}
+
+// usage returns the command usage. The values of bound env vars are shown if env is given.
+func (cmd *Command) usage(env *Environment) string {
 	var buf bytes.Buffer
 
 	fmt.Fprintln(&buf, "\n"+cmd.Short)
 	if len(cmd.Long) > 0 {
 		fmt.Fprintln(&buf, cmd.Long)
 	}
-	fmt.Fprintf(&buf, "\nUsage:\n  %s [flags] [args...]\n", cmd.Use)
+	fmt.Fprintln(&buf, "\nUsage:")
+	if len(cmd.Args) > 0 {
+		fmt.Fprintf(&buf, "  %s\n", cmd.argsUsage())
+	} else if cmd.Run != nil || !cmd.HasSubCommands() {
+		fmt.Fprintf(&buf, "  %s [flags] [args...]\n", cmd.CommandPath())
+	}
+	if cmd.HasSubCommands() {
+		fmt.Fprintf(&buf, "  %s <command> [flags] [args...]\n", cmd.CommandPath())
+	}
+	if len(cmd.Args) > 0 {
+		fmt.Fprintln(&buf, "\nArguments:")
+		fmt.Fprint(&buf, cmd.argsDescription())
+	}
+
 	fmt.Fprintln(&buf, "\nFlags:")
 	cmd.Flags().SetOutput(&buf)
 	cmd.Flags().PrintDefaults()
 
+	if groups := cmd.flagGroupUsage(); len(groups) > 0 {
+		fmt.Fprintln(&buf, "\nFlag groups:")
+		fmt.Fprint(&buf, groups)
+	}
+
+	if bindings := cmd.bindingUsage(env); len(bindings) > 0 {
+		fmt.Fprintln(&buf, "\nBound flags:")
+		fmt.Fprint(&buf, bindings)
+	}
+
+	if cmd.HasSubCommands() {
+		commands := cmd.AvailableCommands()
+		maxLen := getMaxLength(commands)
+
+		fmt.Fprintln(&buf, "\nCommands:")
+		for index := 0; index < len(commands); index++ {
+			fmt.Fprintf(&buf, "  %s    %s\n", padRight(commands[index], " ", maxLen), cmd.commands[commands[index]].Short)
+		}
+	}
+
 	if len(cmd.Aliases) > 0 {
 		fmt.Fprintf(&buf, "\nAliases:\n  %s\n", strings.Join(cmd.Aliases, ", "))
 	}
 	return buf.String()
 }
+
+// bindingUsage lists the bound flags with the source of their value.
+func (cmd *Command) bindingUsage(env *Environment) string {
+	var names, sources []string
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		key, ok := flagBinding(flag)
+		if !ok || flag.Hidden {
+			return
+		}
+
+		source := "$" + key
+		if env != nil {
+			if value, ok := env.Lookup(key); !ok {
+				source += " (not set, default " + flag.DefValue + ")"
+			} else if env.IsLocal(key) {
+				source += " = " + FormatValue(value) + " (local)"
+			} else {
+				source += " = " + FormatValue(value)
+			}
+		}
+		names = append(names, "--"+flag.Name)
+		sources = append(sources, source)
+	})
+
+	var buf bytes.Buffer
+	maxLen := getMaxLength(names)
+	for index := 0; index < len(names); index++ {
+		fmt.Fprintf(&buf, "  %s    %s\n", padRight(names[index], " ", maxLen), sources[index])
+	}
+	return buf.String()
+}
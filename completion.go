@@ -0,0 +1,220 @@
+package console
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c-bata/go-prompt"
+	"github.com/spf13/pflag"
+)
+
+// DefaultCompletionTimeout is the default deadline of the completion providers.
+const DefaultCompletionTimeout = 200 * time.Millisecond
+
+// SuggestionDescriptions and SuggestionGroups are the flag annotations holding the descriptions and
+// groups of the values in the Suggestions annotation. See Command.SetFlagSuggestions.
+const (
+	SuggestionDescriptions = "suggestion_descriptions"
+	SuggestionGroups       = "suggestion_groups"
+)
+
+// Suggestion is a completion suggestion. Suggestions are sorted by group and the group is shown
+// before the description.
+type Suggestion struct {
+	Text        string
+	Description string
+	Group       string
+}
+
+// SetFlagSuggestions sets the suggested values of a flag. The values are also stored in the
+// Suggestions annotation so the plain values remain available.
+func (cmd *Command) SetFlagSuggestions(name string, suggestions ...Suggestion) error {
+	texts := make([]string, len(suggestions))
+	descriptions := make([]string, len(suggestions))
+	groups := make([]string, len(suggestions))
+	for index, sug := range suggestions {
+		texts[index] = sug.Text
+		descriptions[index] = sug.Description
+		groups[index] = sug.Group
+	}
+
+	if err := cmd.Flags().SetAnnotation(name, Suggestions, texts); err != nil {
+		return err
+	}
+	if err := cmd.Flags().SetAnnotation(name, SuggestionDescriptions, descriptions); err != nil {
+		return err
+	}
+	return cmd.Flags().SetAnnotation(name, SuggestionGroups, groups)
+}
+
+// flagSuggestions returns the suggested values of a flag.
+func flagSuggestions(flag *pflag.Flag) []Suggestion {
+	texts := flag.Annotations[Suggestions]
+	descriptions := flag.Annotations[SuggestionDescriptions]
+	groups := flag.Annotations[SuggestionGroups]
+
+	suggestions := make([]Suggestion, len(texts))
+	for index, text := range texts {
+		suggestions[index].Text = text
+		if index < len(descriptions) {
+			suggestions[index].Description = descriptions[index]
+		}
+		if index < len(groups) {
+			suggestions[index].Group = groups[index]
+		}
+	}
+	return suggestions
+}
+
+// promptSuggestions converts suggestions to prompt suggestions sorted by group.
+func promptSuggestions(suggestions []Suggestion) []prompt.Suggest {
+	sorted := make([]Suggestion, len(suggestions))
+	copy(sorted, suggestions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Group < sorted[j].Group
+	})
+
+	converted := make([]prompt.Suggest, len(sorted))
+	for index, sug := range sorted {
+		converted[index] = prompt.Suggest{Text: sug.Text, Description: sug.Description}
+		if sug.Group != "" {
+			converted[index].Description = strings.TrimSpace("[" + sug.Group + "] " + sug.Description)
+		}
+	}
+	return converted
+}
+
+// CompletionProvider provides the suggestions for a command. The args are the completed args
+// starting with the command name, the word being typed is not included. Suggestions are requested
+// while typing so Complete should return once the context is done.
+type CompletionProvider interface {
+	Complete(ctx context.Context, env *Environment, args []string) ([]Suggestion, error)
+}
+
+// CompletionFunc is a function implementing CompletionProvider.
+type CompletionFunc func(ctx context.Context, env *Environment, args []string) ([]Suggestion, error)
+
+// Complete calls fn.
+func (fn CompletionFunc) Complete(ctx context.Context, env *Environment, args []string) ([]Suggestion, error) {
+	return fn(ctx, env, args)
+}
+
+// complete requests the suggestions of the provider within the completion timeout. Errors are
+// dropped as they can not be shown while typing.
+func (env *Environment) complete(provider CompletionProvider, args []string) []Suggestion {
+	timeout := env.CompletionTimeout
+	if timeout <= 0 {
+		timeout = DefaultCompletionTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(env.Context(), timeout)
+	defer cancel()
+
+	suggestions, err := provider.Complete(ctx, env, args)
+	if err != nil {
+		return nil
+	}
+	return suggestions
+}
+
+// NewCachedCompletion caches the suggestions of a provider by the completed args. Cached suggestions
+// are used for ttl and then refreshed in the background while the stale suggestions are still
+// returned. The provider runs in the background with the given timeout so slow providers do not
+// block typing; until the first result arrives no suggestions are returned.
+func NewCachedCompletion(provider CompletionProvider, ttl time.Duration, timeout time.Duration) *CachedCompletion {
+	return &CachedCompletion{
+		provider: provider,
+		ttl:      ttl,
+		timeout:  timeout,
+		entries:  map[string]*cacheEntry{},
+	}
+}
+
+// CachedCompletion is a CompletionProvider caching the suggestions of another provider.
+type CachedCompletion struct {
+	provider CompletionProvider
+	ttl      time.Duration
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	suggestions []Suggestion
+	fetched     time.Time
+	loaded      bool
+
+	// done is closed when the running fetch finishes. It is nil if no fetch is running.
+	done chan struct{}
+}
+
+// Complete returns the cached suggestions and starts a fetch if they are missing or stale. If no
+// suggestions are cached it waits for the fetch until ctx is done.
+func (c *CachedCompletion) Complete(ctx context.Context, env *Environment, args []string) ([]Suggestion, error) {
+	key := strings.Join(args, "\x00")
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cacheEntry{}
+		c.entries[key] = entry
+	}
+	if entry.loaded && time.Since(entry.fetched) < c.ttl {
+		c.mu.Unlock()
+		return entry.suggestions, nil
+	}
+	if entry.done == nil {
+		entry.done = make(chan struct{})
+		go c.fetch(env, args, entry)
+	}
+	loaded, suggestions, done := entry.loaded, entry.suggestions, entry.done
+	c.mu.Unlock()
+
+	if loaded {
+		return suggestions, nil
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return entry.suggestions, nil
+}
+
+// fetch requests the suggestions from the provider and stores them in the entry. The previous
+// suggestions are kept if the provider fails.
+func (c *CachedCompletion) fetch(env *Environment, args []string, entry *cacheEntry) {
+	ctx := context.Background()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	suggestions, err := c.provider.Complete(ctx, env, args)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		entry.suggestions = suggestions
+		entry.fetched = time.Now()
+		entry.loaded = true
+	}
+	close(entry.done)
+	entry.done = nil
+}
+
+// Invalidate removes all the cached suggestions.
+func (c *CachedCompletion) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]*cacheEntry{}
+}
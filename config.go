@@ -0,0 +1,144 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ConfigTypes are the supported config file extensions.
+var ConfigTypes = []string{"yaml", "yml", "toml", "json", "env", "dotenv"}
+
+// SaveConfig writes the global variables to a file. The format is chosen by the file extension, see
+// ConfigTypes. If prefix is given only the keys below it are written, relative to the prefix.
+func (env *Environment) SaveConfig(path string, prefix string) error {
+	configType, err := configType(path)
+	if err != nil {
+		return err
+	}
+
+	prefix = strings.ToLower(prefix)
+	out := viper.New()
+	env.shared.mu.Lock()
+	defer env.shared.mu.Unlock()
+	for _, key := range env.Configuration.AllKeys() {
+		value := env.Configuration.Get(key)
+		if value == nil {
+			continue
+		}
+
+		if prefix != "" {
+			if !strings.HasPrefix(key, prefix+".") {
+				continue
+			}
+			key = strings.TrimPrefix(key, prefix+".")
+		}
+
+		// dotenv files only hold strings and durations are written in a readable form
+		if _, ok := value.(time.Duration); ok || configType == "env" || configType == "dotenv" {
+			value = FormatValue(value)
+		}
+		out.Set(key, value)
+	}
+	return out.WriteConfigAs(path)
+}
+
+// LoadConfig reads a config file into the global variables. The format is chosen by the file
+// extension, see ConfigTypes. If prefix is given the keys are loaded below it.
+func (env *Environment) LoadConfig(path string, prefix string) error {
+	configType, err := configType(path)
+	if err != nil {
+		return err
+	}
+
+	in := viper.New()
+	in.SetConfigFile(path)
+	in.SetConfigType(configType)
+	if err := in.ReadInConfig(); err != nil {
+		return err
+	}
+
+	env.shared.mu.Lock()
+	defer env.shared.mu.Unlock()
+	for _, key := range in.AllKeys() {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+		env.Configuration.Set(name, in.Get(key))
+	}
+	return nil
+}
+
+// configType returns the config type for the file extension.
+func configType(path string) (string, error) {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	for _, typ := range ConfigTypes {
+		if ext == typ {
+			return typ, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported config type %q, use one of %s", ext, strings.Join(ConfigTypes, ", "))
+}
+
+// configFileArg returns the file argument or the config file of the environment.
+func configFileArg(env *Environment, args []string) (string, error) {
+	if len(args) > 1 {
+		return "", errors.New("accepts only 1 argument")
+	} else if len(args) == 1 {
+		return args[0], nil
+	} else if env.ConfigFile == "" {
+		return "", errors.New("requires a file when no config file is set")
+	}
+	return env.ConfigFile, nil
+}
+
+func addConfigCommands(scope *Scope) {
+	save := &Command{
+		Use:   "save",
+		Short: "Saves the env vars to a file. Defaults to the config file of the console.",
+		Long:  "The format is chosen by the file extension: " + strings.Join(ConfigTypes, ", "),
+		Run: func(env *Environment, cmd *Command, args []string) error {
+			path, err := configFileArg(env, args)
+			if err != nil {
+				return err
+			}
+
+			prefix, err := cmd.Flags().GetString("scope")
+			if err != nil {
+				return err
+			}
+			return env.SaveConfig(path, prefix)
+		},
+		IsBuiltIn:       true,
+		ShouldPropagate: true,
+	}
+	save.Flags().String("scope", "", "Saves only the keys below the given key")
+	scope.AddCommand(save)
+
+	load := &Command{
+		Use:   "load",
+		Short: "Loads env vars from a file. Defaults to the config file of the console.",
+		Long:  "The format is chosen by the file extension: " + strings.Join(ConfigTypes, ", "),
+		Run: func(env *Environment, cmd *Command, args []string) error {
+			path, err := configFileArg(env, args)
+			if err != nil {
+				return err
+			}
+
+			prefix, err := cmd.Flags().GetString("scope")
+			if err != nil {
+				return err
+			}
+			return env.LoadConfig(path, prefix)
+		},
+		IsBuiltIn:       true,
+		ShouldPropagate: true,
+	}
+	load.Flags().String("scope", "", "Loads the keys below the given key")
+	scope.AddCommand(load)
+}
@@ -1,26 +1,91 @@
 package console
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"os"
-	"sort"
+	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/c-bata/go-prompt"
 )
 
 // Config is the app configuration.
 type Config struct {
-	Title           string
-	Prefix          string
-	MaxSuggestions  uint16
-	ColorScheme     *ColorScheme
-	TitleScreenFunc func()
+	Title            string
+	Prefix           string
+	PromptTemplate   string
+	MaxSuggestions   uint16
+	ColorScheme      *ColorScheme
+	TitleScreenFunc  func()
+	StopOnError      bool
+	HistoryFile      string
+	HistorySize      int
+	ExternalCommands bool
+	ConfigFile       string
+	AutoSaveConfig   bool
+	Matcher          MatchFunc
+
+	// Key bindings of the prompt. KeyBindingsFile is read with LoadKeyBindings and replaces
+	// KeyBindings. KeyBinds and ASCIICodeBinds are added to the prompts of the environment as is.
+	KeyBindings     *KeyBindings
+	KeyBindingsFile string
+	KeyBinds        []prompt.KeyBind
+	ASCIICodeBinds  []prompt.ASCIICodeBind
+
+	// Completion behaviour
+	CompletionTimeout     time.Duration
+	CompletionOnDown      bool
+	ShowCompletionAtStart bool
+
+	// Streams of the commands. The prompt always uses the terminal.
+	In  io.Reader
+	Out io.Writer
+	Err io.Writer
 }
 
 // New creates a new Console.
 func New(name string, opts ...OptionFunc) *Console {
-	env := NewEnvironment("> ")
+	conf := &Config{
+		Title:             "console",
+		Prefix:            "> ",
+		MaxSuggestions:    8,
+		ColorScheme:       DefaultColorScheme,
+		TitleScreenFunc:   func() {},
+		HistoryFile:       DefaultHistoryFile(name),
+		HistorySize:       DefaultHistorySize,
+		Matcher:           MatchFuzzy,
+		KeyBindings:       NewKeyBindings(EmacsMode),
+		CompletionTimeout: DefaultCompletionTimeout,
+		In:                os.Stdin,
+		Out:               os.Stdout,
+		Err:               os.Stderr,
+	}
+
+	for _, opt := range opts {
+		opt(conf)
+	}
+
+	env := NewEnvironment(conf.Prefix)
+	env.In, env.Out, env.Err = conf.In, conf.Out, conf.Err
+	env.ExternalCommands = conf.ExternalCommands
+	env.Matcher = conf.Matcher
+	env.ColorScheme = conf.ColorScheme
+	env.CompletionTimeout = conf.CompletionTimeout
+	env.CompletionAtStart = conf.ShowCompletionAtStart
+	env.KeyBindings = conf.KeyBindings
+	env.KeyBinds, env.ASCIICodeBinds = conf.KeyBinds, conf.ASCIICodeBinds
+	if conf.KeyBindingsFile != "" {
+		if bindings, err := LoadKeyBindings(conf.KeyBindingsFile); err != nil {
+			env.PrintWarning(err.Error())
+		} else {
+			env.KeyBindings = bindings
+		}
+	}
+
 	rootScope := NewScope(name, "")
 
 	// setup built-in commands
@@ -28,16 +93,34 @@ func New(name string, opts ...OptionFunc) *Console {
 
 	env.Push(rootScope)
 
-	conf := &Config{
-		Title:           "console",
-		Prefix:          "> ",
-		MaxSuggestions:  8,
-		ColorScheme:     DefaultColorScheme,
-		TitleScreenFunc: func() {},
+	if conf.PromptTemplate != "" {
+		if err := env.SetPromptTemplate(conf.PromptTemplate); err != nil {
+			env.PrintWarning(fmt.Sprintf("invalid prompt template: %v", err))
+		}
 	}
 
-	for _, opt := range opts {
-		opt(conf)
+	// load the config file
+	env.ConfigFile = conf.ConfigFile
+	if conf.ConfigFile != "" {
+		if _, err := os.Stat(conf.ConfigFile); err == nil {
+			if err := env.LoadConfig(conf.ConfigFile, ""); err != nil {
+				env.PrintWarning(err.Error())
+			}
+		}
+
+		if conf.AutoSaveConfig {
+			env.OnShutdown(func(env *Environment) {
+				if err := env.SaveConfig(env.ConfigFile, ""); err != nil {
+					env.PrintWarning(err.Error())
+				}
+			})
+		}
+	}
+
+	// load the history
+	env.History = NewHistory(conf.HistoryFile, conf.HistorySize)
+	if err := env.History.Load(); err != nil {
+		env.PrintWarning(err.Error())
 	}
 
 	promptOpts := []prompt.Option{
@@ -45,57 +128,29 @@ func New(name string, opts ...OptionFunc) *Console {
 		prompt.OptionPrefix(conf.Prefix),
 		prompt.OptionLivePrefix(env.LivePrefix),
 		prompt.OptionMaxSuggestion(conf.MaxSuggestions),
-
-		// Text colors
-		prompt.OptionScrollbarThumbColor(conf.ColorScheme.ScrollbarThumbColor),
-		prompt.OptionScrollbarBGColor(conf.ColorScheme.ScrollbarBGColor),
-		prompt.OptionPrefixTextColor(conf.ColorScheme.PrefixTextColor),
-		prompt.OptionInputTextColor(conf.ColorScheme.InputTextColor),
-		prompt.OptionDescriptionBGColor(conf.ColorScheme.DescriptionBGColor),
-		prompt.OptionDescriptionTextColor(conf.ColorScheme.DescriptionTextColor),
-		prompt.OptionSuggestionBGColor(conf.ColorScheme.SuggestionBGColor),
-		prompt.OptionSuggestionTextColor(conf.ColorScheme.SuggestionTextColor),
-		prompt.OptionSelectedSuggestionBGColor(conf.ColorScheme.SelectedSuggestionBGColor),
-		prompt.OptionSelectedSuggestionTextColor(conf.ColorScheme.SelectedSuggestionTextColor),
-		prompt.OptionSelectedDescriptionBGColor(conf.ColorScheme.SelectedDescriptionBGColor),
-		prompt.OptionSelectedDescriptionTextColor(conf.ColorScheme.SelectedDescriptionTextColor),
-
-		// Key bindings for meta key
-		prompt.OptionSwitchKeyBindMode(prompt.EmacsKeyBind),
-		prompt.OptionAddASCIICodeBind(prompt.ASCIICodeBind{
-			ASCIICode: []byte{0x1b, 127},
-			Fn:        prompt.DeleteWord,
-		}),
-		prompt.OptionAddASCIICodeBind(prompt.ASCIICodeBind{
-			ASCIICode: []byte{0x1b, 0x08},
-			Fn:        prompt.DeleteWord,
-		}),
-		prompt.OptionAddASCIICodeBind(prompt.ASCIICodeBind{
-			// ASCIICode: []byte{27, 27, 91, 68},
-			ASCIICode: []byte{0x1b, 98},
-			Fn:        prompt.GoLeftWord,
-		}),
-		prompt.OptionAddASCIICodeBind(prompt.ASCIICodeBind{
-			ASCIICode: []byte{0x1b, 102},
-			Fn:        prompt.GoRightWord,
-		}),
+		prompt.OptionHistory(env.History.Entries()),
+	}
+	if conf.CompletionOnDown {
+		promptOpts = append(promptOpts, prompt.OptionCompletionOnDown())
+	}
+	if conf.ShowCompletionAtStart {
+		promptOpts = append(promptOpts, prompt.OptionShowCompletionAtStart())
 	}
 
-	p := prompt.New(env.ExecutorFunc, env.CompletorFunc, promptOpts...)
 	return &Console{
-		config:    conf,
-		env:       env,
-		rootScope: rootScope,
-		prompt:    p,
+		config:     conf,
+		env:        env,
+		rootScope:  rootScope,
+		promptOpts: promptOpts,
 	}
 }
 
 // Console runs the prompt and manages the environment.
 type Console struct {
-	config    *Config
-	env       *Environment
-	prompt    *prompt.Prompt
-	rootScope *Scope
+	config     *Config
+	env        *Environment
+	promptOpts []prompt.Option
+	rootScope  *Scope
 }
 
 // AddScope adds a scope at the root level.
@@ -113,76 +168,162 @@ func (c *Console) Environment() *Environment {
 	return c.env
 }
 
-// Run runs the console
-func (c *Console) Run() {
+// OnShutdown registers a hook which runs when the console shuts down.
+func (c *Console) OnShutdown(fn func(*Environment)) {
+	c.env.OnShutdown(fn)
+}
+
+// Run runs the console until it is exited and returns the exit code. The prompt requires a terminal
+// so it is only created once the console is run.
+func (c *Console) Run() int {
 	c.config.TitleScreenFunc()
-	c.prompt.Run()
+
+	exitChecker := func(in string, breakline bool) bool {
+		return c.env.Exited()
+	}
+	opts := append(c.promptOpts, prompt.OptionSetExitCheckerOnInput(exitChecker))
+	p := c.env.newPrompt(c.env.ExecutorFunc, c.env.CompletorFunc, true, opts...)
+
+	// The theme command changes the colors while the prompt runs
+	c.env.applyColorScheme = func(scheme *ColorScheme) {
+		for _, opt := range colorOptions(scheme) {
+			opt(p)
+		}
+	}
+	defer func() {
+		c.env.applyColorScheme = nil
+		c.env.shared.mu.Lock()
+		c.env.shared.notifyPrompt = nil
+		c.env.shared.mu.Unlock()
+	}()
+
+	p.Run()
+	return c.Shutdown()
+}
+
+// Shutdown runs the shutdown hooks and returns the exit code. Run calls Shutdown once the prompt
+// exits so it only needs to be called directly after RunScript.
+func (c *Console) Shutdown() int {
+	return c.env.shutdown()
+}
+
+// RunScript executes the commands read from r without starting the interactive prompt.
+func (c *Console) RunScript(r io.Reader) error {
+	return c.env.RunScript(r, c.config.StopOnError)
 }
 
 func addBuiltInCommands(scope *Scope) {
 
-	scope.AddCommand(&Command{
-		Use:   "env",
-		Short: "env lists all the environment variables for the commands",
+	addVariableCommands(scope)
+	addConfigCommands(scope)
+	addThemeCommands(scope)
+
+	source := &Command{
+		Use:   "source",
+		Short: "Executes the commands in a script file",
 		Run: func(env *Environment, cmd *Command, args []string) error {
-			keys := env.Configuration.AllKeys()
-			sort.Strings(keys)
+			if len(args) != 1 {
+				return errors.New("requires 1 argument")
+			}
 
-			maxLen := getMaxLength(keys)
-			for index := 0; index < len(keys); index++ {
-				fmt.Printf("%s   %v\n", padRight(keys[index], " ", maxLen), env.Configuration.Get(keys[index]))
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
 			}
-			return nil
+			defer file.Close()
+
+			stopOnError, err := cmd.Flags().GetBool("stop-on-error")
+			if err != nil {
+				return err
+			}
+			return env.RunScript(file, stopOnError)
 		},
 		IsBuiltIn:       true,
 		ShouldPropagate: true,
-	})
-	scope.AddCommand(&Command{
-		Use:              "get",
-		Short:            "Gets a current env var",
-		EagerSuggestions: true,
-		Suggestions: func(env *Environment, args []string) []string {
-			if len(args) < 2 {
-				keys := env.Configuration.AllKeys()
-				sort.Strings(keys)
-				return keys
-			}
-			return []string{}
-		},
+	}
+	source.Flags().BoolP("stop-on-error", "e", false, "Stops the script at the first failing command")
+	scope.AddCommand(source)
+
+	history := &Command{
+		Use:   "history",
+		Short: "Lists the command history. Entries can be filtered by a regular expression.",
+		Long:  "Entries can be executed again with !n, !-n or !! for the last entry.",
 		Run: func(env *Environment, cmd *Command, args []string) error {
-			if len(args) != 1 {
-				return errors.New("requires 1 argument")
+			if len(args) > 1 {
+				return errors.New("history accepts only 1 argument")
+			}
+
+			clear, err := cmd.Flags().GetBool("clear")
+			if err != nil {
+				return err
+			} else if clear {
+				return env.History.Clear()
+			}
+
+			var pattern *regexp.Regexp
+			if len(args) == 1 {
+				if pattern, err = regexp.Compile(args[0]); err != nil {
+					return err
+				}
+			}
+
+			entries := env.History.Entries()
+			for index := 0; index < len(entries); index++ {
+				if pattern == nil || pattern.MatchString(entries[index]) {
+					fmt.Fprintf(env.Out, "%5d  %s\n", index+1, entries[index])
+				}
 			}
-			fmt.Printf("%s   %v\n", args[0], env.Configuration.Get(args[0]))
 			return nil
 		},
 		IsBuiltIn:       true,
 		ShouldPropagate: true,
-	})
+	}
+	history.Flags().BoolP("clear", "c", false, "Clears the history")
+	scope.AddCommand(history)
 
-	scope.AddCommand(&Command{
-		Use:              "set",
-		Short:            "Sets an env var",
-		EagerSuggestions: true,
-		Suggestions: func(env *Environment, args []string) []string {
-			if len(args) < 2 {
-				keys := env.Configuration.AllKeys()
-				sort.Strings(keys)
-				return keys
-			}
-			return []string{}
-		},
+	grep := &Command{
+		Use:   "grep",
+		Short: "Prints the input lines matching a regular expression",
 		Run: func(env *Environment, cmd *Command, args []string) error {
-			if len(args) != 2 {
-				return errors.New("requires 2 arguments")
+			if len(args) != 1 {
+				return errors.New("requires 1 argument")
 			}
-			env.Configuration.Set(args[0], args[1])
-			// fmt.Printf("%s   %v\n", args[0], env.Configuration.Get(args[0]))
-			return nil
+
+			invert, err := cmd.Flags().GetBool("invert-match")
+			if err != nil {
+				return err
+			}
+			ignoreCase, err := cmd.Flags().GetBool("ignore-case")
+			if err != nil {
+				return err
+			}
+
+			expr := args[0]
+			if ignoreCase {
+				expr = "(?i)" + expr
+			}
+			pattern, err := regexp.Compile(expr)
+			if err != nil {
+				return err
+			}
+
+			scanner := bufio.NewScanner(env.In)
+			for scanner.Scan() {
+				if pattern.MatchString(scanner.Text()) != invert {
+					fmt.Fprintln(env.Out, scanner.Text())
+				}
+			}
+			return scanner.Err()
 		},
 		IsBuiltIn:       true,
 		ShouldPropagate: true,
-	})
+	}
+	grep.Flags().BoolP("invert-match", "v", false, "Prints the lines which do not match")
+	grep.Flags().BoolP("ignore-case", "i", false, "Ignores case when matching")
+	scope.AddCommand(grep)
+
+	addJobCommands(scope)
+
 	scope.AddCommand(&Command{
 		Use:     "exit",
 		Aliases: []string{"pop"},
@@ -197,9 +338,20 @@ func addBuiltInCommands(scope *Scope) {
 
 	scope.AddCommand(&Command{
 		Use:   "quit",
-		Short: "Exits the console regardless of scope",
+		Short: "Exits the console regardless of scope. Accepts an optional exit code.",
 		Run: func(env *Environment, cmd *Command, args []string) error {
-			os.Exit(0)
+			if len(args) > 1 {
+				return errors.New("quit accepts only 1 argument")
+			}
+
+			var code int
+			if len(args) == 1 {
+				var err error
+				if code, err = strconv.Atoi(args[0]); err != nil {
+					return fmt.Errorf("invalid exit code: %s", args[0])
+				}
+			}
+			env.Exit(code)
 			return nil
 		},
 		IsBuiltIn:       true,
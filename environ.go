@@ -1,55 +1,125 @@
 package console
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"sort"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
 
 	"github.com/spf13/viper"
 
 	"github.com/c-bata/go-prompt"
-	"github.com/gookit/color"
-	"github.com/kballard/go-shellquote"
 	"github.com/spf13/pflag"
 )
 
 const Suggestions = "suggestions"
 
+// ConfigKey is the flag annotation holding the env var a flag is bound to. See Command.BindFlag.
+const ConfigKey = "config_key"
+
+// ErrInterrupted is returned when a command is interrupted with Ctrl-C.
+var ErrInterrupted = errors.New("interrupted")
+
 // NewEnvironment creates a new environment with a root scope.
 func NewEnvironment(prefix string) *Environment {
-	env := &Environment{ScopeStack: make([]*Scope, 0), Prefix: prefix, Configuration: viper.New()}
+	env := &Environment{
+		ScopeStack:    make([]*Scope, 0),
+		Prefix:        prefix,
+		Configuration: viper.New(),
+		History:       NewHistory("", DefaultHistorySize),
+		In:            os.Stdin,
+		Out:           os.Stdout,
+		Err:           os.Stderr,
+
+		CompletionTimeout: DefaultCompletionTimeout,
+
+		jobs:   newJobTable(),
+		shared: &sharedState{},
+	}
 	return env
 }
 
+// sharedState is the state an environment shares with the copies running its background jobs.
+type sharedState struct {
+	// mu guards the variables in Configuration and the fields below
+	mu sync.Mutex
+
+	shutdownHooks []func(*Environment)
+
+	// notifyPrompt asks the running prompt to print the job notices
+	notifyPrompt func()
+}
+
 // Environment manages the various cmd scopes
 type Environment struct {
-	Prefix        string
-	ScopeStack    []*Scope
+	Prefix     string
+	ScopeStack []*Scope
+	History    *History
+
+	// Configuration holds the global variables. It is shared with background jobs, so use the
+	// variable methods, e.g. Set and Get, which synchronize the access.
 	Configuration *viper.Viper
-}
 
-// LivePrefix allows for a dynamic prompt prefix
-func (env *Environment) LivePrefix() (string, bool) {
-	scopes := []string{}
-	for index := 0; index < len(env.ScopeStack); index++ {
-		scopes = append(scopes, env.ScopeStack[index].Name)
-	}
-	return strings.Join(scopes, ":") + env.Prefix, true
-}
+	// ConfigFile is the default file for the save and load commands.
+	ConfigFile string
+
+	// In, Out and Err are the streams of the executing command. Commands should read and write
+	// through them rather than os.Stdin and os.Stdout. In and Out are replaced while a pipeline runs.
+	In  io.Reader
+	Out io.Writer
+	Err io.Writer
+
+	// ExternalCommands runs unknown commands as programs on the host.
+	ExternalCommands bool
+
+	// CompletionTimeout is the deadline of the completion providers of the commands.
+	CompletionTimeout time.Duration
+
+	// CompletionAtStart suggests the commands and scopes on an empty line, e.g. before anything is
+	// typed. See WithCompletionAtStart.
+	CompletionAtStart bool
+
+	// Matcher filters the suggestions. Defaults to MatchFuzzy.
+	Matcher MatchFunc
+
+	// ColorScheme sets the colors of the prompt and the output. See SetColorScheme.
+	ColorScheme *ColorScheme
+
+	// KeyBindings are the key bindings of the prompt and the prompts created with NewPrompt.
+	// KeyBinds and ASCIICodeBinds are added to the prompts as is.
+	KeyBindings    *KeyBindings
+	KeyBinds       []prompt.KeyBind
+	ASCIICodeBinds []prompt.ASCIICodeBind
+
+	locals   map[int]map[string]interface{}
+	ctx      context.Context
+	output   string
+	jobs     *jobTable
+	shared   *sharedState
+	exited   bool
+	exitCode int
+	status   int
+
+	// editMode is the vi mode of the active prompt, see PromptData
+	editMode string
+
+	// hideDescriptions removes the descriptions of the suggestions. See the toggle-help key action.
+	hideDescriptions bool
 
-// // Set sets an environment variable
-// func (env *Environment) Set(key, value string) {
-// 	env.EnvVariables[strings.ToUpper(key)] = value
-// }
+	// promptTemplate renders the prompt prefix. See SetPromptTemplate.
+	promptTemplate *template.Template
 
-// // Get returns an env variable.
-// func (env *Environment) Get(key string) string {
-// 	if val, ok := env.EnvVariables[strings.ToUpper(key)]; ok {
-// 		return val
-// 	}
-// 	return ""
-// }
+	// applyColorScheme updates the colors of the running prompt
+	applyColorScheme func(*ColorScheme)
+}
 
 // Push adds a scope to the environment
 func (env *Environment) Push(scope *Scope) {
@@ -64,71 +134,351 @@ func (env *Environment) Len() int {
 	return len(env.ScopeStack)
 }
 
-// Pop removes a scope from the environment. Should never remove the root scope.
+// Pop removes a scope from the environment. Should never remove the root scope. Popping the root
+// scope exits the console instead.
 func (env *Environment) Pop() *Scope {
 	if len(env.ScopeStack) <= 1 {
-		os.Exit(0)
+		env.Exit(0)
 		return nil
 	}
 	scope := env.CurrentScope()
-	env.ScopeStack = env.ScopeStack[:env.Len()-1]
+	env.truncate(env.Len() - 1)
 	return scope
 }
 
+// truncate pops scopes until only n remain. The local variables of the popped scopes are removed.
+func (env *Environment) truncate(n int) {
+	if n < env.Len() {
+		env.ScopeStack = env.ScopeStack[:n]
+	}
+	for depth := range env.locals {
+		if depth >= n {
+			delete(env.locals, depth)
+		}
+	}
+}
+
+// Exit requests the console to stop with the given exit code. The current command finishes before
+// the console returns.
+func (env *Environment) Exit(code int) {
+	env.exited = true
+	env.exitCode = code
+}
+
+// Exited returns true if the console has been asked to exit.
+func (env *Environment) Exited() bool {
+	return env.exited
+}
+
+// ExitCode returns the exit code given to Exit.
+func (env *Environment) ExitCode() int {
+	return env.exitCode
+}
+
+// OnShutdown registers a hook which runs when the console shuts down.
+func (env *Environment) OnShutdown(fn func(*Environment)) {
+	env.shared.mu.Lock()
+	defer env.shared.mu.Unlock()
+	env.shared.shutdownHooks = append(env.shared.shutdownHooks, fn)
+}
+
+// shutdown kills the background jobs, runs the shutdown hooks once and returns the exit code.
+func (env *Environment) shutdown() int {
+	env.killJobs()
+
+	env.shared.mu.Lock()
+	hooks := env.shared.shutdownHooks
+	env.shared.shutdownHooks = nil
+	env.shared.mu.Unlock()
+
+	for index := 0; index < len(hooks); index++ {
+		hooks[index](env)
+	}
+	return env.exitCode
+}
+
 // CurrentScope gets the current scope from the environment
 func (env *Environment) CurrentScope() *Scope {
 	return env.ScopeStack[env.Len()-1]
 }
 
-// ExecutorFunc executes the input.
+// ExecutorFunc executes the input. History references are expanded and the line is added to the history.
+// Background jobs which have finished are reported before and after the line executes.
 func (env *Environment) ExecutorFunc(input string) {
-	if input == "" {
+	env.notifyJobs()
+	defer env.notifyJobs()
+
+	if strings.TrimSpace(input) == "" {
 		return
 	}
 
-	// Parse the input
-	args, err := shellquote.Split(input)
+	line, err := env.History.Expand(input)
 	if err != nil {
-		color.Warn.Println(err.Error())
+		env.setStatus(err)
+		env.PrintError(err)
 		return
+	} else if line != input {
+		fmt.Fprintln(env.Out, line)
 	}
 
-	// Get the current scope
-	scope := env.CurrentScope()
-	if scope == nil {
-		color.Warn.Println("current scope is nil")
-		return
+	if err := env.History.Add(line); err != nil {
+		env.PrintWarning(err.Error())
+	}
+
+	err = env.executeInterruptible(line)
+	env.setStatus(err)
+	if err != nil {
+		env.PrintError(err)
+	}
+}
+
+// Context returns the context of the executing command. It is cancelled when the command is
+// interrupted with Ctrl-C or exceeds its timeout.
+func (env *Environment) Context() context.Context {
+	if env.ctx == nil {
+		return context.Background()
+	}
+	return env.ctx
+}
+
+// executeInterruptible executes the line and cancels the context on SIGINT. Commands are expected to
+// return once the context is done. A second SIGINT stops waiting for a command which ignores the
+// context and returns to the prompt. The command is abandoned and may still write to the output
+// or change the environment until it returns.
+func (env *Environment) executeInterruptible(line string) error {
+	ctx, cancel := context.WithCancel(env.Context())
+	defer cancel()
+
+	parent := env.ctx
+	env.ctx = ctx
+	defer func() {
+		env.ctx = parent
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- env.ExecuteLine(line)
+	}()
+
+	for {
+		select {
+		case err := <-done:
+			if err != nil && ctx.Err() != nil && errors.Is(err, context.Canceled) {
+				return ErrInterrupted
+			}
+			return err
+		case <-signals:
+			if ctx.Err() == nil {
+				cancel()
+				continue
+			}
+			return ErrInterrupted
+		}
+	}
+}
+
+// PrintError writes an error to the error stream.
+func (env *Environment) PrintError(err error) {
+	style := outputStyle(env.colorScheme().ErrorColor, DefaultColorScheme.ErrorColor)
+	fmt.Fprintln(env.Err, style.Render(err.Error()))
+}
+
+// PrintWarning writes a warning to the error stream.
+func (env *Environment) PrintWarning(msg string) {
+	style := outputStyle(env.colorScheme().WarningColor, DefaultColorScheme.WarningColor)
+	fmt.Fprintln(env.Err, style.Render(msg))
+}
+
+// PrintInfo writes info with a colored label to the output stream.
+func (env *Environment) PrintInfo(label string, format string, value ...interface{}) {
+	style := outputStyle(env.colorScheme().InfoLabelColor, DefaultColorScheme.InfoLabelColor)
+	fprintInfo(env.Out, style, label, format, value...)
+}
+
+// SetColorScheme changes the colors of the prompt and the output.
+func (env *Environment) SetColorScheme(scheme *ColorScheme) {
+	env.ColorScheme = scheme
+	if env.applyColorScheme != nil {
+		env.applyColorScheme(scheme)
+	}
+}
+
+func (env *Environment) colorScheme() *ColorScheme {
+	if env.ColorScheme == nil {
+		return DefaultColorScheme
+	}
+	return env.ColorScheme
+}
+
+// NewPrompt creates a prompt with the key bindings and colors of the environment. It is used for
+// the console prompt and nested prompts run by commands, e.g. an interpreter.
+func (env *Environment) NewPrompt(executor prompt.Executor, completer prompt.Completer, opts ...prompt.Option) *prompt.Prompt {
+	return env.newPrompt(executor, completer, false, opts...)
+}
+
+// newPrompt creates a prompt. The history references of the lines are expanded before they are
+// executed if expandHistory is set, so the history of the prompt holds the expanded lines.
+func (env *Environment) newPrompt(executor prompt.Executor, completer prompt.Completer, expandHistory bool, opts ...prompt.Option) *prompt.Prompt {
+	bindings := env.KeyBindings
+	if bindings == nil {
+		bindings = NewKeyBindings(EmacsMode)
+	}
+
+	keys := &keymap{
+		env:      env,
+		bindings: bindings,
+		in:       newInputQueue(),
+		out:      prefixWriter{prompt.NewStdoutWriter(), env},
+	}
+	keys.in.acceptLine = expandHistory
+	promptOpts := append(keys.options(), colorOptions(env.colorScheme())...)
+	return prompt.New(executor, completer, append(promptOpts, opts...)...)
+}
+
+// ExecuteLine parses a single line of input and executes it in the current scope. Commands can be
+// chained with pipes and the output of the last command can be redirected to a file with > or >>.
+// A trailing & runs the line as a background job.
+func (env *Environment) ExecuteLine(input string) error {
+	if strings.TrimSpace(input) == "" {
+		return nil
+	}
+
+	if line, ok := backgroundLine(input); ok {
+		_, err := env.StartJob(line)
+		return err
+	}
+
+	// Parse the input and expand the variables
+	stages, redirect, err := parsePipeline(input, env.expandLine)
+	if err != nil {
+		return err
 	}
 
 	// Execute the command
-	if err := scope.Execute(env, args); err != nil {
-		color.Error.Println(err.Error())
-		return
+	if len(stages) == 1 && redirect == nil {
+		return env.executeStage(stages[0])
 	}
+	return env.executePipeline(stages, redirect)
 }
 
 // CompletorFunc gets the Completer from the current scope.
 func (env *Environment) CompletorFunc(doc prompt.Document) []prompt.Suggest {
 	line := doc.CurrentLine()
-	if strings.TrimSpace(line) == "" {
+	if strings.TrimSpace(line) == "" && !env.CompletionAtStart {
 		return []prompt.Suggest{}
 	}
 
 	// Parse the input
-	args, err := shellquote.Split(doc.TextBeforeCursor())
+	args, err := splitInput(doc.TextBeforeCursor())
 	if err != nil {
 		// color.Warn.Println(err.Error())
 		return []prompt.Suggest{}
 	}
 
-	// Get suggestions from current scope
-	scope := env.CurrentScope()
-	suggestions := GetSuggestions(env, line, scope.Commands(), doc.GetWordBeforeCursor(), args)
-	return prompt.FilterFuzzy(suggestions, doc.GetWordBeforeCursor(), true)
+	// Get suggestions from the current scope or the sub-scope named by the input. The word may
+	// contain quoted spaces so it is taken from the input rather than the prompt.
+	prevWord := doc.GetWordBeforeCursor()
+	word := currentWord(doc.TextBeforeCursor())
+	scope, args, qualifier := completionScope(env.CurrentScope(), args, word)
+	if qualifier != "" {
+		args = nil
+	}
+
+	suggestions := GetSuggestions(env, line, scope.Commands(), word, args)
+	if len(args) == 0 || (len(args) == 1 && word != "") {
+		for _, name := range scope.AvailableScopes() {
+			suggestions = append(suggestions, prompt.Suggest{Text: name, Description: scope.subScopes[name].Description})
+		}
+	}
+
+	if qualifier != "" {
+		for index := range suggestions {
+			suggestions[index].Text = qualifier + suggestions[index].Text
+		}
+	}
+
+	// Filter with the matcher of the command being completed or the console
+	matcher := env.Matcher
+	if cmd := completionCommand(scope, args, word); cmd != nil && cmd.Matcher != nil {
+		matcher = cmd.Matcher
+	}
+	if matcher == nil {
+		matcher = MatchFuzzy
+	}
+	suggestions = matcher(env, suggestions, prevWord)
+	if env.hideDescriptions {
+		hidden := make([]prompt.Suggest, len(suggestions))
+		for index, suggestion := range suggestions {
+			hidden[index] = prompt.Suggest{Text: suggestion.Text}
+		}
+		return hidden
+	}
+	return suggestions
+}
+
+// completionCommand returns the command or sub-command being completed. Returns nil while the
+// command name is typed.
+func completionCommand(scope *Scope, args []string, word string) *Command {
+	if len(args) == 0 || (len(args) == 1 && word != "") {
+		return nil
+	}
+
+	cmd, ok := scope.commands[args[0]]
+	if !ok {
+		return nil
+	}
+
+	completed := args[1:]
+	if word != "" {
+		completed = completed[:len(completed)-1]
+	}
+	cmd, _ = cmd.findCommand(completed)
+	return cmd
+}
+
+// completionScope walks into the sub-scopes named by the leading args, e.g. `binance risk` or
+// `binance:risk`. It returns the scope, the remaining args and the scope qualifier of a partially
+// typed `scope:command` word.
+func completionScope(scope *Scope, args []string, prevWord string) (*Scope, []string, string) {
+	var qualifier string
+	for len(args) > 0 {
+		if _, ok := scope.commands[args[0]]; ok {
+			break
+		}
+
+		typing := len(args) == 1 && prevWord != ""
+		if !typing || strings.Contains(args[0], ":") {
+			sub, rest, ok := scope.findSubScope(args)
+			if !ok {
+				break
+			}
+
+			if typing {
+				qualifier += args[0][:len(args[0])-len(rest[0])]
+			}
+			scope, args = sub, rest
+			continue
+		}
+		break
+	}
+	return scope, args, qualifier
 }
 
-// GetSuggestions returns the suggestions for the given input and commands.
+// GetSuggestions returns the suggestions for the given input and commands. Command names are
+// suggested until the first argument is complete. prevWord is the word being typed, it is empty if a
+// new word is started.
 func GetSuggestions(env *Environment, line string, commands map[string]*Command, prevWord string, args []string) []prompt.Suggest {
+	if len(args) > 1 || (len(args) == 1 && prevWord == "") {
+		if cmd, ok := commands[args[0]]; ok {
+			return getCommandSuggestions(env, line, cmd, prevWord, args)
+		}
+		return []prompt.Suggest{}
+	}
+
 	rootCompletions := []prompt.Suggest{}
 
 	var commandNames []string
@@ -141,22 +491,6 @@ func GetSuggestions(env *Environment, line string, commands map[string]*Command,
 		name := commandNames[index]
 		cmd := commands[name]
 
-		if strings.HasPrefix(line, cmd.Use) {
-			if len(args) > 0 && args[0] == cmd.Use {
-				return getCommandSuggestions(env, line, cmd, prevWord, args[0:])
-			}
-			return getCommandSuggestions(env, line, cmd, prevWord, args)
-		}
-
-		for _, alias := range cmd.Aliases {
-			if strings.HasPrefix(line, alias) {
-				if len(args) > 0 && args[0] == alias {
-					return getCommandSuggestions(env, line, cmd, prevWord, args[0:])
-				}
-				return getCommandSuggestions(env, line, cmd, prevWord, args)
-			}
-		}
-
 		sug := prompt.Suggest{Text: name, Description: cmd.Short}
 		if name != cmd.Use {
 			sug.Description = fmt.Sprintf("Alias for `%s`. %s", cmd.Use, cmd.Short)
@@ -169,31 +503,152 @@ func GetSuggestions(env *Environment, line string, commands map[string]*Command,
 func getCommandSuggestions(env *Environment, line string, cmd *Command, prevWord string, args []string) []prompt.Suggest {
 	var suggestions []prompt.Suggest
 
+	// Resolve the sub-commands named by the completed args
+	completed := args[1:]
+	if len(prevWord) > 0 && len(completed) > 0 {
+		completed = completed[:len(completed)-1]
+	}
+	cmd, n := cmd.findCommand(completed)
+	args, completed = args[n:], completed[n:]
+
+	// Only values are suggested while typing the value of a flag
+	if flag, prefix, ok := completingFlag(cmd, completed, prevWord); ok {
+		return flagValueSuggestions(env, flag, prefix, prevWord)
+	}
+
+	// Words after `--` are never flags
+	var afterDash bool
+	for _, word := range completed {
+		afterDash = afterDash || word == "--"
+	}
+	typingFlag := !afterDash && strings.HasPrefix(prevWord, "-")
+
+	// Add sub-command suggestions
+	if cmd.HasSubCommands() && len(completed) == 0 && !typingFlag {
+		for _, name := range cmd.AvailableCommands() {
+			suggestions = append(suggestions, prompt.Suggest{Text: name, Description: cmd.commands[name].Short})
+		}
+	}
+
+	// Add the suggestions of the completion provider
+	if cmd.Completer != nil && !typingFlag {
+		provided := args
+		if len(prevWord) > 0 {
+			provided = args[:len(args)-1]
+		}
+		suggestions = append(suggestions, promptSuggestions(env.complete(cmd.Completer, provided))...)
+	}
+
 	// Add args suggestions
-	if len(prevWord) > 0 || cmd.EagerSuggestions {
+	if (len(prevWord) > 0 || cmd.EagerSuggestions) && !typingFlag {
 		for _, sug := range cmd.Suggestions(env, args) {
 			suggestions = append(suggestions, prompt.Suggest{Text: sug})
 		}
+		if cmd.RichSuggestions != nil {
+			suggestions = append(suggestions, promptSuggestions(cmd.RichSuggestions(env, args))...)
+		}
 	}
 
-	// Add flags
+	// Add the suggestions of the positional arg being completed
+	if arg, ok := cmd.argAt(len(positionalArgs(cmd, completed))); ok && !typingFlag {
+		for _, sug := range arg.suggestions(env, args) {
+			suggestions = append(suggestions, prompt.Suggest{Text: sug, Description: arg.Description})
+		}
+		if arg.Type == ArgPath || arg.Type == ArgDir {
+			suggestions = append(suggestions, completePath("", prevWord, arg.Type == ArgDir, arg.Extensions)...)
+		}
+	}
+
+	// Add the flags which are not given yet
+	if !afterDash && (prevWord == "" || typingFlag) {
+		suggestions = append(suggestions, flagNameSuggestions(cmd, completed, prevWord)...)
+	}
+	return suggestions
+}
+
+// flagNameSuggestions suggests the flags which are not used in the completed words. Flags which can
+// be repeated, e.g. slices, are always suggested. Shorthands are suggested once a single dash is
+// typed.
+func flagNameSuggestions(cmd *Command, completed []string, prevWord string) []prompt.Suggest {
+	used := usedFlags(cmd, completed)
+	shorthands := strings.HasPrefix(prevWord, "-") && !strings.HasPrefix(prevWord, "--")
+
+	var suggestions []prompt.Suggest
 	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
-		if !flag.Hidden {
-			suggestions = append(suggestions, prompt.Suggest{Text: "--" + flag.Name, Description: flag.Usage})
+		if flag.Hidden {
+			return
+		}
+		if _, isSlice := flag.Value.(pflag.SliceValue); used[flag.Name] && !isSlice && flag.Value.Type() != "count" {
+			return
+		}
+
+		if shorthands && flag.Shorthand != "" {
+			suggestions = append(suggestions, prompt.Suggest{Text: "-" + flag.Shorthand, Description: "--" + flag.Name + ": " + flag.Usage})
 		}
+		suggestions = append(suggestions, prompt.Suggest{Text: "--" + flag.Name, Description: flag.Usage})
 	})
+	return suggestions
+}
 
-	// Add matching flag suggestions
-	if strings.HasPrefix(prevWord, "-") && strings.HasSuffix(prevWord, "=") {
-		flagString := strings.TrimLeft(prevWord, "-")
-		flagString = strings.TrimRight(flagString, "=")
+// flagValueSuggestions suggests the values of a flag. prefix is the part of the word before the
+// value, e.g. `--side=`.
+func flagValueSuggestions(env *Environment, flag *pflag.Flag, prefix string, prevWord string) []prompt.Suggest {
+	var suggestions []prompt.Suggest
 
-		flag := cmd.Flags().Lookup(flagString)
-		if flagSuggestions, ok := flag.Annotations[Suggestions]; ok {
-			for _, sug := range flagSuggestions {
-				suggestions = append(suggestions, prompt.Suggest{Text: sug})
-			}
+	// Suggest the current value of a bound env var
+	if key, ok := flagBinding(flag); ok {
+		if value, ok := env.Lookup(key); ok {
+			suggestions = append(suggestions, prompt.Suggest{Text: prefix + FormatValue(value), Description: "Current value of $" + key})
 		}
 	}
+
+	for _, sug := range promptSuggestions(flagSuggestions(flag)) {
+		sug.Text = prefix + sug.Text
+		suggestions = append(suggestions, sug)
+	}
+
+	if flag.Value.Type() == "bool" {
+		suggestions = append(suggestions, prompt.Suggest{Text: prefix + "true"}, prompt.Suggest{Text: prefix + "false"})
+	}
+
+	if dirOnly, extensions, ok := flagPathFilter(flag); ok {
+		suggestions = append(suggestions, completePath(prefix, prevWord, dirOnly, extensions)...)
+	}
 	return suggestions
 }
+
+// usedFlags returns the names of the flags given in the completed words.
+func usedFlags(cmd *Command, completed []string) map[string]bool {
+	used := map[string]bool{}
+	for _, word := range completed {
+		if word == "--" {
+			break
+		} else if !strings.HasPrefix(word, "-") || word == "-" {
+			continue
+		}
+
+		name := strings.SplitN(word, "=", 2)[0]
+		if strings.HasPrefix(name, "--") {
+			if flag := cmd.Flags().Lookup(name[2:]); flag != nil {
+				used[flag.Name] = true
+			}
+			continue
+		}
+
+		// Shorthands can be combined, e.g. `-vf file`. They are single ASCII characters.
+		for index := 1; index < len(name); index++ {
+			if name[index] >= utf8.RuneSelf {
+				break
+			}
+			flag := cmd.Flags().ShorthandLookup(name[index : index+1])
+			if flag == nil {
+				break
+			}
+			used[flag.Name] = true
+			if flag.NoOptDefVal == "" {
+				break
+			}
+		}
+	}
+	return used
+}
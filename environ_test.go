@@ -0,0 +1,68 @@
+package console
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/c-bata/go-prompt"
+)
+
+func newFlagCommand() *Command {
+	cmd := &Command{Use: "set"}
+	cmd.Flags().BoolP("verbose", "v", false, "")
+	cmd.Flags().StringP("file", "f", "", "")
+	cmd.Flags().String("type", "", "")
+	initCommand(cmd)
+	return cmd
+}
+
+func TestUsedFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		want  map[string]bool
+	}{
+		{"none", []string{"a", "b"}, map[string]bool{}},
+		{"long", []string{"--type", "int"}, map[string]bool{"type": true}},
+		{"long with value", []string{"--type=int"}, map[string]bool{"type": true}},
+		{"unknown long", []string{"--nope"}, map[string]bool{}},
+		{"shorthand", []string{"-v"}, map[string]bool{"verbose": true}},
+		{"combined", []string{"-vf", "x"}, map[string]bool{"verbose": true, "file": true}},
+		{"value ends group", []string{"-fv"}, map[string]bool{"file": true}},
+		{"after dash dash", []string{"--", "-v"}, map[string]bool{}},
+		{"non-ascii", []string{"-é"}, map[string]bool{}},
+		{"non-ascii after shorthand", []string{"-vé"}, map[string]bool{"verbose": true}},
+		{"non-ascii before shorthand", []string{"-év"}, map[string]bool{}},
+	}
+
+	cmd := newFlagCommand()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usedFlags(cmd, tt.words); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("usedFlags(%q) = %v, want %v", tt.words, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompletorFuncEmptyLine(t *testing.T) {
+	env := NewEnvironment("> ")
+	scope := NewScope("app", "")
+	scope.AddCommand(&Command{Use: "show", Short: "show things"})
+	scope.AddSubScope(NewScope("db", "database"))
+	env.Push(scope)
+
+	doc := *prompt.NewBuffer().Document()
+	if got := env.CompletorFunc(doc); len(got) != 0 {
+		t.Errorf("CompletorFunc() = %v, want no suggestions", got)
+	}
+
+	env.CompletionAtStart = true
+	texts := map[string]bool{}
+	for _, suggestion := range env.CompletorFunc(doc) {
+		texts[suggestion.Text] = true
+	}
+	if !texts["show"] || !texts["db"] {
+		t.Errorf("CompletorFunc() = %v, want show and db", texts)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/eliquious/console"
 	"github.com/eliquious/console/ext/js"
 )
@@ -18,11 +20,17 @@ func main() {
 	cmd := &console.Command{
 		Use:   "risk",
 		Short: "risk calculates an investment risk",
+		Args: []console.Arg{
+			{Name: "symbol", Description: "Trading pair", Type: console.ArgEnum, Values: []string{"BTCUSDT", "ETHUSDT"}},
+			{Name: "amount", Description: "Amount to invest", Type: console.ArgFloat},
+			{Name: "leverage", Description: "Leverage of the position", Type: console.ArgInt, Optional: true},
+		},
 		Run: func(env *console.Environment, cmd *console.Command, args []string) error {
 			return nil
 		},
 	}
 	cmd.Flags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.cobra.yaml)")
+	cmd.MarkFlagFilename("config", "yaml", "yml")
 	cmd.Flags().StringP("author", "a", "YOUR NAME", "author name for copyright attribution")
 	cmd.Flags().StringVarP(&userLicense, "license", "l", "", "name of license for the project")
 	cmd.Flags().Bool("viper", true, "use Viper for configuration")
@@ -34,5 +42,5 @@ func main() {
 	// add global js interpreter
 	shell.AddCommand(js.EvalCommand())
 
-	shell.Run()
+	os.Exit(shell.Run())
 }
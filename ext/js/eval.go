@@ -13,14 +13,6 @@ import (
 // EvalCommand creates a command that initializes a JS interpretter.
 func EvalCommand() *console.Command {
 
-	// console config for the eval prompt
-	conf := &console.Config{
-		Title:           "goja",
-		Prefix:          "eval",
-		ColorScheme:     console.DefaultColorScheme,
-		TitleScreenFunc: func() {},
-	}
-
 	// dynamic prefix function
 	var environ *console.Environment
 	prefixFunc := func() (string, bool) {
@@ -39,24 +31,30 @@ func EvalCommand() *console.Command {
 	executor := func(line string) {
 		line = strings.TrimSpace(line)
 		if line == "pop" || line == "exit" {
-			fmt.Println("Press Ctrl-D to exit")
+			fmt.Fprintln(environ.Out, "Press Ctrl-D to exit")
 			return
 		}
 
 		val, err := vm.RunString(line)
 		if err != nil {
-			fmt.Printf(colors.Red("error: ", err))
+			fmt.Fprintln(environ.Err, colors.Red("error: ", err))
+			return
 		}
-		fmt.Println(val)
+		fmt.Fprintln(environ.Out, val)
 	}
-	evalPrompt := newEvalPrompt(conf, prefixFunc, executor)
 
+	// The eval prompt shares the key bindings and colors of the console. It is created on the first
+	// run as the prompt requires a terminal.
+	var evalPrompt *prompt.Prompt
 	command := &console.Command{
 		Use:              "eval",
 		Short:            "Launch JS interpreter",
 		EagerSuggestions: true,
 		Run: func(env *console.Environment, cmd *console.Command, args []string) error {
 			environ = env
+			if evalPrompt == nil {
+				evalPrompt = newEvalPrompt(env, prefixFunc, executor)
+			}
 			evalPrompt.Run()
 			return nil
 		},
@@ -66,44 +64,11 @@ func EvalCommand() *console.Command {
 	return command
 }
 
-func newEvalPrompt(conf *console.Config, prefixFunc func() (string, bool), execFunc prompt.Executor) *prompt.Prompt {
-
-	promptOpts := []prompt.Option{
-		prompt.OptionTitle(conf.Title),
-		prompt.OptionPrefix(conf.Prefix),
-		prompt.OptionLivePrefix(prefixFunc),
-		prompt.OptionMaxSuggestion(conf.MaxSuggestions),
-
-		// Text colors
-		prompt.OptionScrollbarThumbColor(conf.ColorScheme.ScrollbarThumbColor),
-		prompt.OptionScrollbarBGColor(conf.ColorScheme.ScrollbarBGColor),
-		prompt.OptionPrefixTextColor(conf.ColorScheme.PrefixTextColor),
-		prompt.OptionInputTextColor(conf.ColorScheme.InputTextColor),
-		prompt.OptionDescriptionBGColor(conf.ColorScheme.DescriptionBGColor),
-		prompt.OptionDescriptionTextColor(conf.ColorScheme.DescriptionTextColor),
-		prompt.OptionSuggestionBGColor(conf.ColorScheme.SuggestionBGColor),
-		prompt.OptionSuggestionTextColor(conf.ColorScheme.SuggestionTextColor),
-		prompt.OptionSelectedSuggestionBGColor(conf.ColorScheme.SelectedSuggestionBGColor),
-		prompt.OptionSelectedSuggestionTextColor(conf.ColorScheme.SelectedSuggestionTextColor),
-		prompt.OptionSelectedDescriptionBGColor(conf.ColorScheme.SelectedDescriptionBGColor),
-		prompt.OptionSelectedDescriptionTextColor(conf.ColorScheme.SelectedDescriptionTextColor),
-
-		// Key bindings for meta key
-		prompt.OptionSwitchKeyBindMode(prompt.EmacsKeyBind),
-		prompt.OptionAddASCIICodeBind(prompt.ASCIICodeBind{
-			ASCIICode: []byte{0x1b, 0x7f},
-			Fn:        prompt.DeleteWord,
-		}),
-		prompt.OptionAddASCIICodeBind(prompt.ASCIICodeBind{
-			ASCIICode: []byte{27, 27, 91, 68},
-			Fn:        prompt.GoLeftWord,
-		}),
-		prompt.OptionAddASCIICodeBind(prompt.ASCIICodeBind{
-			ASCIICode: []byte{0x1b, 102},
-			Fn:        prompt.GoRightWord,
-		}),
-	}
-
+func newEvalPrompt(env *console.Environment, prefixFunc func() (string, bool), execFunc prompt.Executor) *prompt.Prompt {
 	completer := func(prompt.Document) []prompt.Suggest { return []prompt.Suggest{} }
-	return prompt.New(execFunc, completer, promptOpts...)
+	return env.NewPrompt(execFunc, completer,
+		prompt.OptionTitle("goja"),
+		prompt.OptionPrefix("eval"),
+		prompt.OptionLivePrefix(prefixFunc),
+	)
 }
@@ -0,0 +1,159 @@
+package console
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// validateFlags checks the required flags and the flag groups.
+func (cmd *Command) validateFlags() error {
+	var missing []string
+	for _, name := range cmd.RequiredFlags {
+		if !cmd.flagGiven(name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 1 {
+		return fmt.Errorf("%s flag is required", formatFlags(missing, ""))
+	} else if len(missing) > 1 {
+		return fmt.Errorf("%s flags are required", formatFlags(missing, "and"))
+	}
+
+	for _, group := range cmd.RequiredTogether {
+		var given, missing []string
+		for _, name := range group {
+			if cmd.flagGiven(name) {
+				given = append(given, name)
+			} else {
+				missing = append(missing, name)
+			}
+		}
+		if len(given) > 0 && len(missing) > 0 {
+			return fmt.Errorf("%s must be used together, missing %s", formatFlags(group, "and"), formatFlags(missing, "and"))
+		}
+	}
+
+	for _, group := range cmd.MutuallyExclusive {
+		var given []string
+		for _, name := range group {
+			if flag := cmd.Flags().Lookup(name); flag != nil && flag.Changed {
+				given = append(given, name)
+			}
+		}
+		if len(given) > 1 {
+			return fmt.Errorf("%s can not be used together", formatFlags(given, "and"))
+		}
+	}
+
+	for _, group := range cmd.OneRequired {
+		var given bool
+		for _, name := range group {
+			given = given || cmd.flagGiven(name)
+		}
+		if !given {
+			return fmt.Errorf("one of %s is required", formatFlags(group, "or"))
+		}
+	}
+
+	for _, name := range sortedKeys(cmd.FlagImplies) {
+		if !cmd.flagGiven(name) {
+			continue
+		}
+
+		var missing []string
+		for _, implied := range cmd.FlagImplies[name] {
+			if !cmd.flagGiven(implied) {
+				missing = append(missing, implied)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%s requires %s", formatFlags([]string{name}, ""), formatFlags(missing, "and"))
+		}
+	}
+	return cmd.validateFlagValues()
+}
+
+// validateFlagValues validates the values of the given flags with the FlagValidators. Slice flags
+// are validated as several args.
+func (cmd *Command) validateFlagValues() error {
+	var names []string
+	for name := range cmd.FlagValidators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !cmd.flagGiven(name) {
+			continue
+		}
+
+		flag := cmd.Flags().Lookup(name)
+		values := []string{flag.Value.String()}
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			values = slice.GetSlice()
+		}
+
+		if err := cmd.FlagValidators[name](values); err != nil {
+			var argErr *ArgError
+			if errors.As(err, &argErr) {
+				return &ArgError{Index: argErr.Index, Flag: flag.Name, Value: argErr.Value, Err: argErr.Err}
+			}
+			return &ArgError{Flag: flag.Name, Value: flag.Value.String(), Err: err}
+		}
+	}
+	return nil
+}
+
+// flagGiven returns true if the flag was given on the command line or set from a bound env var.
+func (cmd *Command) flagGiven(name string) bool {
+	flag := cmd.Flags().Lookup(name)
+	return flag != nil && (flag.Changed || cmd.bound[flag.Name])
+}
+
+// flagGroupUsage describes the required flags and the flag groups.
+func (cmd *Command) flagGroupUsage() string {
+	var buf bytes.Buffer
+	if len(cmd.RequiredFlags) > 0 {
+		fmt.Fprintf(&buf, "  required: %s\n", formatFlags(cmd.RequiredFlags, ""))
+	}
+	for _, group := range cmd.RequiredTogether {
+		fmt.Fprintf(&buf, "  required together: %s\n", formatFlags(group, ""))
+	}
+	for _, group := range cmd.MutuallyExclusive {
+		fmt.Fprintf(&buf, "  mutually exclusive: %s\n", formatFlags(group, ""))
+	}
+	for _, group := range cmd.OneRequired {
+		fmt.Fprintf(&buf, "  one required: %s\n", formatFlags(group, ""))
+	}
+	for _, name := range sortedKeys(cmd.FlagImplies) {
+		fmt.Fprintf(&buf, "  %s requires %s\n", formatFlags([]string{name}, ""), formatFlags(cmd.FlagImplies[name], ""))
+	}
+	return buf.String()
+}
+
+// formatFlags lists flag names, e.g. `--a, --b and --c`. The names are separated by commas only if
+// conjunction is empty.
+func formatFlags(names []string, conjunction string) string {
+	flags := make([]string, len(names))
+	for index := range names {
+		flags[index] = "--" + names[index]
+	}
+	if conjunction == "" || len(flags) < 2 {
+		return strings.Join(flags, ", ")
+	}
+	return strings.Join(flags[:len(flags)-1], ", ") + " " + conjunction + " " + flags[len(flags)-1]
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
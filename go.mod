@@ -9,4 +9,5 @@ require (
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51
 	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.7.1
+	gopkg.in/yaml.v2 v2.4.0
 )
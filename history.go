@@ -0,0 +1,223 @@
+package console
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultHistorySize is the default number of history entries which are kept.
+const DefaultHistorySize = 1000
+
+// DefaultHistoryFile returns the default history file for a console name, ~/.<name>_history.
+func DefaultHistoryFile(name string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, "."+name+"_history")
+}
+
+// NewHistory creates a new history. If path is empty the history is only kept in memory.
+func NewHistory(path string, maxSize int) *History {
+	if maxSize <= 0 {
+		maxSize = DefaultHistorySize
+	}
+	return &History{path: path, maxSize: maxSize}
+}
+
+// History keeps track of the executed command lines. Entries are unique; adding an existing entry
+// moves it to the end of the history.
+type History struct {
+	path    string
+	maxSize int
+
+	// mu guards the entries which are read by background jobs
+	mu      sync.Mutex
+	entries []string
+}
+
+// Path returns the history file. Returns an empty string if the history is only kept in memory.
+func (h *History) Path() string {
+	return h.path
+}
+
+// Load reads the history file. The file is compacted if it contains duplicates or too many entries.
+func (h *History) Load() error {
+	if h.path == "" {
+		return nil
+	}
+
+	file, err := os.Open(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var lines int
+	h.entries = nil
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.TrimSpace(line) != "" {
+			h.add(line)
+			lines++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if lines != len(h.entries) {
+		return h.save()
+	}
+	return nil
+}
+
+// Add appends a line to the history and the history file. The file is rewritten if an existing
+// entry moves to the end or the oldest entry is dropped.
+func (h *History) Add(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	compacted := h.add(line)
+
+	if h.path == "" {
+		return nil
+	} else if compacted {
+		return h.save()
+	}
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintln(file, line)
+	return err
+}
+
+// add appends a line to the entries. Returns true if an existing entry was removed or the entries
+// were trimmed to the maximum size.
+func (h *History) add(line string) bool {
+	var compacted bool
+	for index := 0; index < len(h.entries); index++ {
+		if h.entries[index] == line {
+			h.entries = append(h.entries[:index], h.entries[index+1:]...)
+			compacted = true
+			break
+		}
+	}
+
+	h.entries = append(h.entries, line)
+	if len(h.entries) > h.maxSize {
+		h.entries = h.entries[len(h.entries)-h.maxSize:]
+		compacted = true
+	}
+	return compacted
+}
+
+// Clear removes all the entries and truncates the history file.
+func (h *History) Clear() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = nil
+	if h.path == "" {
+		return nil
+	}
+	return h.save()
+}
+
+func (h *History) save() error {
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for index := 0; index < len(h.entries); index++ {
+		fmt.Fprintln(writer, h.entries[index])
+	}
+	return writer.Flush()
+}
+
+// Entries returns a copy of the history entries, oldest first.
+func (h *History) Entries() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string{}, h.entries...)
+}
+
+// Len returns the number of entries.
+func (h *History) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.entries)
+}
+
+// Get returns the nth entry. Entries are numbered from 1.
+func (h *History) Get(n int) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.get(n)
+}
+
+func (h *History) get(n int) (string, bool) {
+	if n < 1 || n > len(h.entries) {
+		return "", false
+	}
+	return h.entries[n-1], true
+}
+
+// Expand replaces a leading history reference with the matching entry. `!!` refers to the last
+// entry, `!n` to entry n and `!-n` to the nth last entry. Any remaining input is appended to the
+// entry. Lines without a history reference are returned unchanged.
+func (h *History) Expand(line string) (string, error) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "!") {
+		return line, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ref, rest := trimmed, ""
+	if index := strings.IndexAny(trimmed, " \t"); index >= 0 {
+		ref, rest = trimmed[:index], trimmed[index:]
+	}
+
+	var n int
+	switch {
+	case ref == "!!":
+		n = len(h.entries)
+	case len(ref) > 1:
+		num, err := strconv.Atoi(ref[1:])
+		if err != nil {
+			return line, nil
+		}
+		n = num
+		if num < 0 {
+			n = len(h.entries) + num + 1
+		}
+	default:
+		return line, nil
+	}
+
+	entry, ok := h.get(n)
+	if !ok {
+		return "", fmt.Errorf("%s: event not found", ref)
+	}
+	return entry + rest, nil
+}
@@ -0,0 +1,49 @@
+package console
+
+import "testing"
+
+func TestHistoryExpand(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    string
+		wantErr bool
+	}{
+		{"no reference", "ls -l", "ls -l", false},
+		{"last", "!!", "cd db", false},
+		{"last with args", "!! extra", "cd db extra", false},
+		{"number", "!1", "echo a", false},
+		{"negative number", "!-2", "ls -l", false},
+		{"leading spaces", "  !2", "ls -l", false},
+		{"reference later in the line", "echo !!", "echo !!", false},
+		{"not a number", "!abc", "!abc", false},
+		{"bang only", "!", "!", false},
+		{"out of range", "!4", "", true},
+		{"zero", "!0", "", true},
+		{"negative out of range", "!-4", "", true},
+	}
+
+	history := NewHistory("", 0)
+	for _, line := range []string{"echo a", "ls -l", "cd db"} {
+		if err := history.Add(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := history.Expand(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expand(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			} else if got != tt.want {
+				t.Errorf("Expand(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryExpandEmpty(t *testing.T) {
+	if _, err := NewHistory("", 0).Expand("!!"); err == nil {
+		t.Error("Expand(\"!!\") on an empty history should fail")
+	}
+}
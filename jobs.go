@@ -0,0 +1,429 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kballard/go-shellquote"
+)
+
+// Job is a command line running in the background.
+type Job struct {
+	ID   int
+	Line string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	output *jobOutput
+
+	// mu guards the result of the job
+	mu       sync.Mutex
+	err      error
+	notified bool
+}
+
+// Done returns true if the job has finished.
+func (j *Job) Done() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Err returns the error of a finished job.
+func (j *Job) Err() error {
+	if !j.Done() {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+// Status returns a short description of the job state.
+func (j *Job) Status() string {
+	if !j.Done() {
+		return "Running"
+	}
+
+	err := j.Err()
+	if errors.Is(err, context.Canceled) {
+		return "Killed"
+	} else if err != nil {
+		return "Failed: " + err.Error()
+	}
+	return "Done"
+}
+
+// finish records the result of the job.
+func (j *Job) finish(err error) {
+	j.mu.Lock()
+	j.err = err
+	j.mu.Unlock()
+	close(j.done)
+}
+
+// setNotified marks a finished job as reported. Returns false if it was already reported or is
+// still running.
+func (j *Job) setNotified() bool {
+	if !j.Done() {
+		return false
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.notified {
+		return false
+	}
+	j.notified = true
+	return true
+}
+
+// Kill cancels the context of the job.
+func (j *Job) Kill() {
+	j.cancel()
+}
+
+// Wait blocks until the job has finished or the context is done.
+func (j *Job) Wait(ctx context.Context) error {
+	select {
+	case <-j.done:
+		return j.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MaxJobOutput is the number of bytes of output buffered for a background job. Older output is
+// dropped so jobs running for a long time, e.g. pollers, do not use up the memory.
+var MaxJobOutput = 1 << 20
+
+// jobOutput buffers the output of a job until it is attached to a writer.
+type jobOutput struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	dropped int
+	w       io.Writer
+}
+
+func (o *jobOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.w != nil {
+		return o.w.Write(p)
+	}
+
+	o.buf.Write(p)
+	if extra := o.buf.Len() - MaxJobOutput; extra > 0 {
+		o.buf.Next(extra)
+		o.dropped += extra
+	}
+	return len(p), nil
+}
+
+// attach writes the buffered output to w and forwards any further output.
+func (o *jobOutput) attach(w io.Writer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.dropped > 0 {
+		fmt.Fprintf(w, "... %d bytes of output dropped\n", o.dropped)
+		o.dropped = 0
+	}
+	w.Write(o.buf.Bytes())
+	o.buf.Reset()
+	o.w = w
+}
+
+func (o *jobOutput) detach() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.w = nil
+}
+
+// jobTable holds the background jobs of an environment.
+type jobTable struct {
+	mu   sync.Mutex
+	next int
+	jobs map[int]*Job
+}
+
+func newJobTable() *jobTable {
+	return &jobTable{jobs: map[int]*Job{}}
+}
+
+func (t *jobTable) add(job *Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.next++
+	job.ID = t.next
+	t.jobs[job.ID] = job
+}
+
+func (t *jobTable) remove(id int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.jobs, id)
+	if len(t.jobs) == 0 {
+		t.next = 0
+	}
+}
+
+func (t *jobTable) get(id int) (*Job, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	job, ok := t.jobs[id]
+	return job, ok
+}
+
+// list returns the jobs ordered by ID.
+func (t *jobTable) list() []*Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	jobs := make([]*Job, 0, len(t.jobs))
+	for _, job := range t.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+// Jobs returns the background jobs ordered by ID. Finished jobs are kept until they are brought to
+// the foreground or killed.
+func (env *Environment) Jobs() []*Job {
+	return env.jobs.list()
+}
+
+// Job returns a background job by ID.
+func (env *Environment) Job(id int) (*Job, bool) {
+	return env.jobs.get(id)
+}
+
+// StartJob executes the line in the background. The job runs with a copy of the environment so
+// scope changes and local variables set by the job do not affect the prompt. Global variables are
+// shared. Its output is buffered until the job is brought to the foreground and a notice is printed
+// above the prompt when it finishes.
+func (env *Environment) StartJob(line string) (*Job, error) {
+	if strings.TrimSpace(line) == "" {
+		return nil, errors.New("no command given")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		Line:   line,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		output: &jobOutput{},
+	}
+
+	jobEnv := *env
+	jobEnv.ScopeStack = append([]*Scope{}, env.ScopeStack...)
+	jobEnv.locals = map[int]map[string]interface{}{}
+	for depth, locals := range env.locals {
+		jobEnv.locals[depth] = map[string]interface{}{}
+		for key, value := range locals {
+			jobEnv.locals[depth][key] = value
+		}
+	}
+	jobEnv.ctx = ctx
+	jobEnv.In = strings.NewReader("")
+	jobEnv.Out = job.output
+	jobEnv.Err = job.output
+	jobEnv.output = ""
+
+	// The prompt is only changed by the console
+	jobEnv.applyColorScheme = nil
+	jobEnv.promptTemplate = nil
+
+	env.jobs.add(job)
+	go func() {
+		defer cancel()
+		job.finish(jobEnv.ExecuteLine(line))
+
+		env.shared.mu.Lock()
+		notify := env.shared.notifyPrompt
+		env.shared.mu.Unlock()
+		if notify != nil {
+			notify()
+		}
+	}()
+
+	fmt.Fprintf(env.Out, "[%d] %s\n", job.ID, line)
+	return job, nil
+}
+
+// jobNotices returns the notices of the jobs which finished since the last notification.
+func (env *Environment) jobNotices() []string {
+	var notices []string
+	for _, job := range env.jobs.list() {
+		if job.setNotified() {
+			notices = append(notices, fmt.Sprintf("[%d]+ %s  %s", job.ID, job.Status(), job.Line))
+		}
+	}
+	return notices
+}
+
+// notifyJobs prints the jobs which finished since the last notification.
+func (env *Environment) notifyJobs() {
+	for _, notice := range env.jobNotices() {
+		fmt.Fprintln(env.Out, notice)
+	}
+}
+
+// killJobs cancels all the running jobs.
+func (env *Environment) killJobs() {
+	for _, job := range env.jobs.list() {
+		job.Kill()
+	}
+}
+
+// backgroundLine returns the line without a trailing '&' if it should run as a job.
+func backgroundLine(input string) (string, bool) {
+	line := strings.TrimSpace(input)
+	if !strings.HasSuffix(line, "&") || hasContinuation(line[:len(line)-1]) {
+		return input, false
+	}
+	return strings.TrimSpace(line[:len(line)-1]), true
+}
+
+func addJobCommands(scope *Scope) {
+	bg := &Command{
+		Use:   "bg",
+		Short: "Runs a command in the background. A trailing & also runs a command line in the background.",
+		Run: func(env *Environment, cmd *Command, args []string) error {
+			_, err := env.StartJob(shellquote.Join(args...))
+			return err
+		},
+		IsBuiltIn:       true,
+		ShouldPropagate: true,
+	}
+	// The flags after the command name are flags of the job
+	bg.Flags().SetInterspersed(false)
+	scope.AddCommand(bg)
+
+	scope.AddCommand(&Command{
+		Use:   "jobs",
+		Short: "Lists the background jobs",
+		Run: func(env *Environment, cmd *Command, args []string) error {
+			for _, job := range env.Jobs() {
+				job.setNotified()
+				fmt.Fprintf(env.Out, "[%d]  %s  %s\n", job.ID, job.Status(), job.Line)
+			}
+			return nil
+		},
+		IsBuiltIn:       true,
+		ShouldPropagate: true,
+	})
+
+	scope.AddCommand(&Command{
+		Use:              "fg",
+		Short:            "Prints the output of a background job and waits for it to finish",
+		Long:             "Interrupting the job in the foreground kills it.",
+		RichSuggestions:  jobSuggestions,
+		EagerSuggestions: true,
+		Run: func(env *Environment, cmd *Command, args []string) error {
+			job, err := jobArg(env, args)
+			if err != nil {
+				return err
+			}
+
+			job.output.attach(env.Out)
+			defer job.output.detach()
+
+			if err := job.Wait(env.Context()); err != nil && !job.Done() {
+				job.Kill()
+				<-job.done
+			}
+			job.setNotified()
+			env.jobs.remove(job.ID)
+			return job.Err()
+		},
+		IsBuiltIn:       true,
+		ShouldPropagate: true,
+	})
+
+	scope.AddCommand(&Command{
+		Use:              "wait",
+		Short:            "Waits for a background job to finish. Waits for all jobs without an argument.",
+		RichSuggestions:  jobSuggestions,
+		EagerSuggestions: true,
+		Run: func(env *Environment, cmd *Command, args []string) error {
+			jobs := env.Jobs()
+			if len(args) > 0 {
+				job, err := jobArg(env, args)
+				if err != nil {
+					return err
+				}
+				jobs = []*Job{job}
+			}
+
+			for _, job := range jobs {
+				if err := job.Wait(env.Context()); err != nil && !job.Done() {
+					return err
+				}
+				job.setNotified()
+				fmt.Fprintf(env.Out, "[%d]  %s  %s\n", job.ID, job.Status(), job.Line)
+			}
+			return nil
+		},
+		IsBuiltIn:       true,
+		ShouldPropagate: true,
+	})
+
+	scope.AddCommand(&Command{
+		Use:              "kill",
+		Short:            "Kills a background job. Finished jobs are removed along with their output.",
+		RichSuggestions:  jobSuggestions,
+		EagerSuggestions: true,
+		Run: func(env *Environment, cmd *Command, args []string) error {
+			job, err := jobArg(env, args)
+			if err != nil {
+				return err
+			}
+
+			if job.Done() {
+				env.jobs.remove(job.ID)
+				return nil
+			}
+			job.Kill()
+			return nil
+		},
+		IsBuiltIn:       true,
+		ShouldPropagate: true,
+	})
+}
+
+// jobArg returns the job for the single ID argument. A leading '%' is allowed.
+func jobArg(env *Environment, args []string) (*Job, error) {
+	if len(args) != 1 {
+		return nil, errors.New("requires 1 argument")
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "%"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid job id: %s", args[0])
+	}
+
+	job, ok := env.Job(id)
+	if !ok {
+		return nil, fmt.Errorf("unknown job: %d", id)
+	}
+	return job, nil
+}
+
+func jobSuggestions(env *Environment, args []string) []Suggestion {
+	var suggestions []Suggestion
+	for _, job := range env.Jobs() {
+		suggestions = append(suggestions, Suggestion{Text: strconv.Itoa(job.ID), Description: job.Line, Group: job.Status()})
+	}
+	return suggestions
+}
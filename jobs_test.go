@@ -0,0 +1,27 @@
+package console
+
+import "testing"
+
+func TestBackgroundLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		ok    bool
+	}{
+		{"foreground", "sleep 1", "sleep 1", false},
+		{"background", "sleep 1 &", "sleep 1", true},
+		{"trailing spaces", "  sleep 1 &  ", "sleep 1", true},
+		{"escaped", `echo a \&`, `echo a \&`, false},
+		{"escaped backslash", `echo a \\&`, `echo a \\`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := backgroundLine(tt.input)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("backgroundLine(%q) = %q, %v, want %q, %v", tt.input, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,610 @@
+package console
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/c-bata/go-prompt"
+	"github.com/spf13/viper"
+)
+
+// KeyMode is the editing mode of the prompt.
+type KeyMode string
+
+// Editing modes. Vi mode starts in insert mode, escape switches to normal mode.
+const (
+	EmacsMode KeyMode = "emacs"
+	ViMode    KeyMode = "vi"
+)
+
+// KeyAction is run when a bound key is pressed. See RegisterKeyAction.
+type KeyAction func(env *Environment, buf *prompt.Buffer)
+
+// keyFunc is an action with access to the prompt it runs in.
+type keyFunc func(m *keymap, buf *prompt.Buffer)
+
+func bufferAction(fn func(buf *prompt.Buffer)) keyFunc {
+	return func(m *keymap, buf *prompt.Buffer) {
+		fn(buf)
+	}
+}
+
+var keyActions = map[string]keyFunc{
+	"beginning-of-line":    bufferAction(prompt.GoLineBeginning),
+	"end-of-line":          bufferAction(prompt.GoLineEnd),
+	"forward-char":         bufferAction(prompt.GoRightChar),
+	"backward-char":        bufferAction(prompt.GoLeftChar),
+	"forward-word":         bufferAction(prompt.GoRightWord),
+	"backward-word":        bufferAction(prompt.GoLeftWord),
+	"delete-char":          bufferAction(prompt.DeleteChar),
+	"backward-delete-char": bufferAction(prompt.DeleteBeforeChar),
+	"backward-kill-word":   bufferAction(prompt.DeleteWord),
+	"kill-line":            bufferAction(killLine),
+	"unix-line-discard":    bufferAction(discardLine),
+	"kill-whole-line":      bufferAction(killWholeLine),
+	"clear-screen": func(m *keymap, buf *prompt.Buffer) {
+		m.out.EraseScreen()
+		m.out.CursorGoTo(0, 0)
+		m.out.Flush()
+	},
+	"toggle-help": func(m *keymap, buf *prompt.Buffer) {
+		m.env.hideDescriptions = !m.env.hideDescriptions
+	},
+
+	// The vi modes are switched by the input parser of the prompt, see viParser
+	"vi-normal-mode": func(m *keymap, buf *prompt.Buffer) {},
+	"vi-insert-mode": func(m *keymap, buf *prompt.Buffer) {},
+}
+
+// RegisterKeyAction adds a named action which can be bound to keys.
+func RegisterKeyAction(name string, action KeyAction) {
+	keyActions[name] = func(m *keymap, buf *prompt.Buffer) {
+		action(m.env, buf)
+	}
+}
+
+// KeyActionNames returns the sorted names of the registered key actions.
+func KeyActionNames() []string {
+	names := make([]string, 0, len(keyActions))
+	for name := range keyActions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupKeyAction returns a registered action or an action running the command line of a
+// `run <command>` action.
+func lookupKeyAction(action string) (keyFunc, error) {
+	if strings.HasPrefix(action, "run ") {
+		line := strings.TrimSpace(action[len("run "):])
+		return func(m *keymap, buf *prompt.Buffer) {
+			m.run(buf, line)
+		}, nil
+	}
+
+	fn, ok := keyActions[action]
+	if !ok {
+		return nil, fmt.Errorf("unknown key action: %s", action)
+	}
+	return fn, nil
+}
+
+func killLine(buf *prompt.Buffer) {
+	buf.Delete(len([]rune(buf.Document().TextAfterCursor())))
+}
+
+func discardLine(buf *prompt.Buffer) {
+	buf.DeleteBeforeCursor(len([]rune(buf.Document().TextBeforeCursor())))
+}
+
+func killWholeLine(buf *prompt.Buffer) {
+	killLine(buf)
+	discardLine(buf)
+}
+
+// emacsBindings are added to the emacs keys of the prompt, e.g. ctrl-a or ctrl-k.
+var emacsBindings = map[string]string{
+	"ctrl-left":     "backward-word",
+	"ctrl-right":    "forward-word",
+	"alt-b":         "backward-word",
+	"alt-f":         "forward-word",
+	"alt-left":      "backward-word",
+	"alt-right":     "forward-word",
+	"alt-backspace": "backward-kill-word",
+}
+
+var viBindings = map[string]string{
+	"escape":     "vi-normal-mode",
+	"ctrl-h":     "backward-delete-char",
+	"ctrl-u":     "unix-line-discard",
+	"ctrl-w":     "backward-kill-word",
+	"ctrl-l":     "clear-screen",
+	"ctrl-left":  "backward-word",
+	"ctrl-right": "forward-word",
+}
+
+// KeyBindings maps keys to named actions. Keys are named like `ctrl-a`, `alt-b`, `alt-backspace`,
+// `escape`, `pageup` or `f5` and actions like `beginning-of-line`, see KeyActionNames. The
+// `run <command>` action runs a command line as if it was entered.
+//
+// The prompt handles Enter, Tab, Ctrl-C, Ctrl-D, the arrow keys, home, end, delete and backspace
+// itself, and in EmacsMode the emacs keys ctrl-a, ctrl-e, ctrl-f, ctrl-b, ctrl-d, ctrl-h, ctrl-k,
+// ctrl-u, ctrl-w and ctrl-l. Actions bound to these keys run in addition to their default behaviour.
+type KeyBindings struct {
+	// Mode selects the emacs keys of the prompt in EmacsMode and enables the vi normal mode
+	// commands in ViMode. The default bindings of the mode are set by NewKeyBindings.
+	Mode KeyMode
+
+	keys map[string]string
+}
+
+// NewKeyBindings creates the default key bindings of the mode.
+func NewKeyBindings(mode KeyMode) *KeyBindings {
+	defaults := emacsBindings
+	if mode == ViMode {
+		defaults = viBindings
+	}
+
+	kb := &KeyBindings{Mode: mode, keys: map[string]string{}}
+	for key, action := range defaults {
+		kb.keys[key] = action
+	}
+	return kb
+}
+
+// Bind binds a key to an action, replacing its current action. The action `none` removes the
+// binding.
+func (kb *KeyBindings) Bind(key string, action string) error {
+	key = normalizeKey(key)
+	if _, _, err := keyCodes(key); err != nil {
+		return err
+	}
+
+	action = strings.TrimSpace(action)
+	if action == "none" {
+		delete(kb.keys, key)
+		return nil
+	}
+	if _, err := lookupKeyAction(action); err != nil {
+		return err
+	}
+	kb.keys[key] = action
+	return nil
+}
+
+// Unbind removes the binding of a key.
+func (kb *KeyBindings) Unbind(key string) {
+	delete(kb.keys, normalizeKey(key))
+}
+
+// Action returns the action bound to a key.
+func (kb *KeyBindings) Action(key string) (string, bool) {
+	action, ok := kb.keys[normalizeKey(key)]
+	return action, ok
+}
+
+// Keys returns the sorted names of the bound keys.
+func (kb *KeyBindings) Keys() []string {
+	keys := make([]string, 0, len(kb.keys))
+	for key := range kb.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// LoadKeyBindings reads key bindings from a yaml, json or toml file. The `mode` key selects the
+// default bindings, emacs or vi, and the `bindings` key maps keys to actions, e.g.
+// `ctrl-r: run history` or `alt-b: none`. Keys are not case sensitive in files, so alt-B is read as
+// alt-b.
+func LoadKeyBindings(path string) (*KeyBindings, error) {
+	configType, err := configType(path)
+	if err != nil {
+		return nil, err
+	}
+
+	in := viper.New()
+	in.SetConfigFile(path)
+	in.SetConfigType(configType)
+	if err := in.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	mode := EmacsMode
+	switch name := KeyMode(strings.ToLower(in.GetString("mode"))); name {
+	case "", EmacsMode:
+	case ViMode:
+		mode = ViMode
+	default:
+		return nil, fmt.Errorf("unknown key mode: %s", name)
+	}
+
+	kb := NewKeyBindings(mode)
+	bindings := in.GetStringMapString("bindings")
+	keys := make([]string, 0, len(bindings))
+	for key := range bindings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := kb.Bind(key, bindings[key]); err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	return kb, nil
+}
+
+var keyNames = map[string]prompt.Key{
+	"escape":       prompt.Escape,
+	"ctrl-space":   prompt.ControlSpace,
+	"ctrl-left":    prompt.ControlLeft,
+	"ctrl-right":   prompt.ControlRight,
+	"ctrl-up":      prompt.ControlUp,
+	"ctrl-down":    prompt.ControlDown,
+	"ctrl-delete":  prompt.ControlDelete,
+	"up":           prompt.Up,
+	"down":         prompt.Down,
+	"left":         prompt.Left,
+	"right":        prompt.Right,
+	"shift-left":   prompt.ShiftLeft,
+	"shift-right":  prompt.ShiftRight,
+	"shift-up":     prompt.ShiftUp,
+	"shift-down":   prompt.ShiftDown,
+	"shift-delete": prompt.ShiftDelete,
+	"shift-tab":    prompt.BackTab,
+	"home":         prompt.Home,
+	"end":          prompt.End,
+	"delete":       prompt.Delete,
+	"backspace":    prompt.Backspace,
+	"insert":       prompt.Insert,
+	"pageup":       prompt.PageUp,
+	"pagedown":     prompt.PageDown,
+	"tab":          prompt.Tab,
+	"enter":        prompt.Enter,
+}
+
+func init() {
+	for ch := 'a'; ch <= 'z'; ch++ {
+		keyNames["ctrl-"+string(ch)] = prompt.ControlA + prompt.Key(ch-'a')
+	}
+	for n := 1; n <= 12; n++ {
+		keyNames[fmt.Sprintf("f%d", n)] = prompt.F1 + prompt.Key(n-1)
+	}
+}
+
+// altSequences are the sequences sent for alt with keys other than characters. Terminals differ in
+// the sequences of the arrow keys.
+var altSequences = map[string][][]byte{
+	"alt-backspace": {{0x1b, 0x7f}, {0x1b, 0x08}},
+	"alt-left":      {{0x1b, 0x1b, '[', 'D'}, {0x1b, '[', '1', ';', '3', 'D'}},
+	"alt-right":     {{0x1b, 0x1b, '[', 'C'}, {0x1b, '[', '1', ';', '3', 'C'}},
+}
+
+// keyCodes returns the key known to the prompt or the input sequences of a key name.
+func keyCodes(name string) (prompt.Key, [][]byte, error) {
+	if key, ok := keyNames[name]; ok {
+		return key, nil, nil
+	} else if sequences, ok := altSequences[name]; ok {
+		return prompt.NotDefined, sequences, nil
+	} else if strings.HasPrefix(name, "alt-") && len(name) == len("alt-")+1 && isPrintable(name[4]) {
+		return prompt.NotDefined, [][]byte{{0x1b, name[4]}}, nil
+	}
+	return prompt.NotDefined, nil, fmt.Errorf("unknown key: %s", name)
+}
+
+// keyPrefixes are the accepted spellings of the modifiers.
+var keyPrefixes = map[string]string{
+	"control-": "ctrl-",
+	"c-":       "ctrl-",
+	"meta-":    "alt-",
+	"m-":       "alt-",
+}
+
+// normalizeKey lowercases a key name and the spelling of its modifier, e.g. `C-a` is ctrl-a. The
+// character of alt keys keeps its case as alt-B and alt-b are different keys.
+func normalizeKey(key string) string {
+	key = strings.TrimSpace(key)
+	lower := strings.ToLower(key)
+	for alias, prefix := range keyPrefixes {
+		if strings.HasPrefix(lower, alias) {
+			key, lower = prefix+key[len(alias):], prefix+lower[len(alias):]
+			break
+		}
+	}
+
+	if strings.HasPrefix(lower, "alt-") && len(key) == len("alt-")+1 {
+		return "alt-" + key[4:]
+	}
+	return lower
+}
+
+func isPrintable(ch byte) bool {
+	return ch >= 0x20 && ch < 0x7f
+}
+
+// inputQueue feeds input to the prompt before the input of the terminal. Actions use it to press
+// keys handled by the prompt, e.g. Enter to run a command.
+type inputQueue struct {
+	prompt.ConsoleParser
+	pending chan []byte
+
+	// acceptLine sends acceptLineInput instead of the keys breaking the line, see keymap.acceptLine.
+	acceptLine bool
+}
+
+func newInputQueue() *inputQueue {
+	return &inputQueue{ConsoleParser: prompt.NewStandardInputParser(), pending: make(chan []byte, 8)}
+}
+
+func (q *inputQueue) Read() ([]byte, error) {
+	select {
+	case input := <-q.pending:
+		return input, nil
+	default:
+		input, err := q.ConsoleParser.Read()
+		return q.enter(input), err
+	}
+}
+
+// enter returns the input sent to the prompt for a key read from the terminal.
+func (q *inputQueue) enter(input []byte) []byte {
+	switch prompt.GetKey(input) {
+	case prompt.Enter, prompt.ControlJ, prompt.ControlM:
+		if q.acceptLine {
+			return acceptLineInput
+		}
+	}
+	return input
+}
+
+func (q *inputQueue) push(input []byte) {
+	select {
+	case q.pending <- input:
+	default:
+	}
+}
+
+// keymap runs the bound actions of a prompt.
+type keymap struct {
+	env      *Environment
+	bindings *KeyBindings
+	in       *inputQueue
+	out      prompt.ConsoleWriter
+}
+
+// options returns the prompt options binding the keys.
+func (m *keymap) options() []prompt.Option {
+	var parser prompt.ConsoleParser = m.in
+	mode := prompt.EmacsKeyBind
+	if m.bindings.Mode == ViMode {
+		parser = newViParser(m.in, m.bindings, m.env.ASCIICodeBinds)
+		mode = prompt.CommonKeyBind
+	}
+
+	opts := []prompt.Option{
+		prompt.OptionParser(parser),
+		prompt.OptionWriter(m.out),
+		prompt.OptionSwitchKeyBindMode(mode),
+		prompt.OptionBreakLineCallback(func(*prompt.Document) {
+			m.setNormal(false)
+		}),
+		prompt.OptionAddASCIICodeBind(prompt.ASCIICodeBind{
+			ASCIICode: jobNoticeInput,
+			Fn:        m.printJobNotices,
+		}),
+		prompt.OptionAddASCIICodeBind(prompt.ASCIICodeBind{
+			ASCIICode: acceptLineInput,
+			Fn:        m.acceptLine,
+		}),
+	}
+
+	// Finished jobs wake the prompt to print their notices
+	m.env.shared.mu.Lock()
+	m.env.shared.notifyPrompt = func() {
+		m.in.push(jobNoticeInput)
+	}
+	m.env.shared.mu.Unlock()
+
+	for _, name := range m.bindings.Keys() {
+		action, _ := m.bindings.Action(name)
+		fn, err := lookupKeyAction(action)
+		if err != nil {
+			continue
+		}
+		bind := func(buf *prompt.Buffer) {
+			fn(m, buf)
+		}
+
+		key, sequences, err := keyCodes(name)
+		if err != nil {
+			continue
+		} else if key != prompt.NotDefined {
+			opts = append(opts, prompt.OptionAddKeyBind(prompt.KeyBind{Key: key, Fn: bind}))
+		}
+		for _, sequence := range sequences {
+			opts = append(opts, prompt.OptionAddASCIICodeBind(prompt.ASCIICodeBind{ASCIICode: sequence, Fn: bind}))
+		}
+	}
+
+	// The binds of the environment run in addition to the bound actions
+	opts = append(opts,
+		prompt.OptionAddKeyBind(m.env.KeyBinds...),
+		prompt.OptionAddASCIICodeBind(m.env.ASCIICodeBinds...),
+	)
+
+	if m.bindings.Mode == ViMode {
+		m.setNormal(false)
+		opts = append(opts, m.viOptions()...)
+	}
+	return opts
+}
+
+// viOptions binds the inputs sent by the vi parser to switch the mode and run the normal mode
+// commands.
+func (m *keymap) viOptions() []prompt.Option {
+	opts := []prompt.Option{
+		prompt.OptionAddASCIICodeBind(prompt.ASCIICodeBind{
+			ASCIICode: viNormalInput,
+			Fn: func(buf *prompt.Buffer) {
+				buf.CursorLeft(1)
+				m.setNormal(true)
+			},
+		}),
+		prompt.OptionAddASCIICodeBind(prompt.ASCIICodeBind{
+			ASCIICode: viInsertInput,
+			Fn: func(buf *prompt.Buffer) {
+				m.setNormal(false)
+			},
+		}),
+	}
+
+	var commands []string
+	for _, ch := range viCommands {
+		commands = append(commands, string(ch))
+	}
+	for _, op := range viOperators {
+		for _, motion := range string(op) + viMotions {
+			commands = append(commands, string([]rune{op, motion}))
+		}
+	}
+	for _, command := range commands {
+		command := command
+		opts = append(opts, prompt.OptionAddASCIICodeBind(prompt.ASCIICodeBind{
+			ASCIICode: viCommandInput(command),
+			Fn: func(buf *prompt.Buffer) {
+				m.viCommand(buf, command)
+			},
+		}))
+	}
+	return opts
+}
+
+// setNormal shows the vi mode in the prompt.
+func (m *keymap) setNormal(normal bool) {
+	if m.bindings.Mode != ViMode {
+		return
+	}
+
+	m.env.editMode = "insert"
+	if normal {
+		m.env.editMode = "normal"
+	}
+}
+
+// viCommand runs a command of the vi normal mode sent by the vi parser. Operators are sent along
+// with their motion, e.g. dw.
+func (m *keymap) viCommand(buf *prompt.Buffer, command string) {
+	if len(command) == 2 {
+		m.viOperator(buf, command[0], command[1])
+		return
+	}
+
+	switch command[0] {
+	case 'h':
+		buf.CursorLeft(1)
+	case 'l':
+		buf.CursorRight(1)
+	case '0', '^', 'I':
+		prompt.GoLineBeginning(buf)
+	case '$', 'A':
+		prompt.GoLineEnd(buf)
+	case 'w':
+		prompt.GoRightWord(buf)
+	case 'b':
+		prompt.GoLeftWord(buf)
+	case 'x', 's':
+		buf.Delete(1)
+	case 'X':
+		buf.DeleteBeforeCursor(1)
+	case 'D', 'C':
+		killLine(buf)
+	case 'a':
+		buf.CursorRight(1)
+	case 'S':
+		killWholeLine(buf)
+	}
+}
+
+// viOperator runs the d or c operator with a motion, e.g. dw. Doubling the operator applies it to
+// the whole line.
+func (m *keymap) viOperator(buf *prompt.Buffer, op byte, motion byte) {
+	switch motion {
+	case op:
+		killWholeLine(buf)
+	case 'w':
+		buf.Delete(buf.Document().FindEndOfCurrentWordWithSpace())
+	case 'b':
+		prompt.DeleteWord(buf)
+	case '$':
+		killLine(buf)
+	case '0', '^':
+		discardLine(buf)
+	}
+}
+
+// run replaces the input with a command line and presses Enter.
+func (m *keymap) run(buf *prompt.Buffer, line string) {
+	killWholeLine(buf)
+	buf.InsertText(line, false, true)
+	m.in.push([]byte{'\r'})
+}
+
+// acceptLineInput is sent by the parser instead of Enter on the console prompt. It is not sent by
+// terminals.
+var acceptLineInput = []byte("\x1b[accept-line]")
+
+// acceptLine replaces the history references of the input with the lines they refer to and presses
+// Enter. The prompt adds the input to its history when Enter is pressed, so the up arrow recalls
+// the expanded line rather than e.g. !!. Lines referring to unknown entries are left to the
+// executor, which prints the error.
+func (m *keymap) acceptLine(buf *prompt.Buffer) {
+	text := buf.Text()
+	if line, err := m.env.History.Expand(text); err == nil && line != text {
+		m.run(buf, line)
+		return
+	}
+	m.in.push([]byte{'\r'})
+}
+
+// jobNoticeInput is fed to the prompt when a job finishes. It is not sent by terminals.
+var jobNoticeInput = []byte("\x1b[job-notice]")
+
+// printJobNotices prints the notices of the finished jobs above the prompt. The prompt is rendered
+// again below the notices.
+func (m *keymap) printJobNotices(buf *prompt.Buffer) {
+	notices := m.env.jobNotices()
+	if len(notices) == 0 {
+		return
+	}
+
+	// The prompt moves up by the rows before the cursor when it is rendered again
+	var rows int
+	prefix, _ := m.env.LivePrefix()
+	if size := m.in.GetWinSize(); size != nil && size.Col > 0 {
+		rows = (displayWidth(prefix) + displayWidth(buf.Document().TextBeforeCursor())) / int(size.Col)
+	}
+
+	m.out.CursorUp(rows)
+	m.out.WriteRawStr("\r")
+	m.out.EraseDown()
+	for _, notice := range notices {
+		m.out.WriteStr(notice)
+		m.out.WriteRawStr("\r\n")
+	}
+	m.out.WriteRawStr(strings.Repeat("\r\n", rows))
+	m.out.Flush()
+}
+
+// displayWidth returns the number of printed characters. Wide characters are counted once.
+func displayWidth(text string) int {
+	var width int
+	for _, ch := range text {
+		if ch >= 0x20 {
+			width++
+		}
+	}
+	return width
+}
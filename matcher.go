@@ -0,0 +1,108 @@
+package console
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/c-bata/go-prompt"
+)
+
+// MatchFunc filters the suggestions by the word being typed. See Config.Matcher and Command.Matcher.
+type MatchFunc func(env *Environment, suggestions []prompt.Suggest, word string) []prompt.Suggest
+
+// MatchPrefix keeps the suggestions starting with the word, ignoring case.
+func MatchPrefix(env *Environment, suggestions []prompt.Suggest, word string) []prompt.Suggest {
+	return prompt.FilterHasPrefix(suggestions, word, true)
+}
+
+// MatchContains keeps the suggestions containing the word, ignoring case.
+func MatchContains(env *Environment, suggestions []prompt.Suggest, word string) []prompt.Suggest {
+	return prompt.FilterContains(suggestions, word, true)
+}
+
+// MatchFuzzy keeps the suggestions containing the letters of the word in order, ignoring case. It
+// is the default.
+func MatchFuzzy(env *Environment, suggestions []prompt.Suggest, word string) []prompt.Suggest {
+	return prompt.FilterFuzzy(suggestions, word, true)
+}
+
+// MatchRanked keeps the same suggestions as MatchFuzzy but orders them by the quality of the match
+// and how recently they were used in the history. Exact and prefix matches come first.
+func MatchRanked(env *Environment, suggestions []prompt.Suggest, word string) []prompt.Suggest {
+	recent := recentWords(env.History, 200)
+
+	type ranked struct {
+		suggestion prompt.Suggest
+		score      float64
+	}
+
+	var matches []ranked
+	for _, sug := range suggestions {
+		score, ok := matchScore(sug.Text, word)
+		if !ok {
+			continue
+		}
+		if rank, ok := recent[sug.Text]; ok {
+			score += 30 / float64(1+rank)
+		}
+		matches = append(matches, ranked{sug, score})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	filtered := make([]prompt.Suggest, len(matches))
+	for index := range matches {
+		filtered[index] = matches[index].suggestion
+	}
+	return filtered
+}
+
+// matchScore scores a fuzzy match of the word in the text. Returns false if the text does not match.
+func matchScore(text string, word string) (float64, bool) {
+	text, word = strings.ToLower(text), strings.ToLower(word)
+	switch {
+	case word == "":
+		return 0, true
+	case text == word:
+		return 100, true
+	case strings.HasPrefix(text, word):
+		return 50, true
+	case strings.Contains(text, word):
+		return 25, true
+	}
+
+	// Fuzzy matches lose points for the letters skipped between the matched ones
+	var gaps, last int
+	for index := 0; index < len(word); index++ {
+		found := strings.IndexByte(text[last:], word[index])
+		if found < 0 {
+			return 0, false
+		}
+		if index > 0 {
+			gaps += found
+		}
+		last += found + 1
+	}
+	return 20 / float64(1+gaps), true
+}
+
+// recentWords returns the words of the latest history entries with their rank, 0 being the latest
+// entry.
+func recentWords(history *History, limit int) map[string]int {
+	words := map[string]int{}
+	if history == nil {
+		return words
+	}
+
+	entries := history.Entries()
+	for rank := 0; rank < limit && rank < len(entries); rank++ {
+		for _, word := range strings.Fields(entries[len(entries)-1-rank]) {
+			if _, ok := words[word]; !ok {
+				words[word] = rank
+			}
+		}
+	}
+	return words
+}
@@ -1,11 +1,178 @@
 package console
 
+import (
+	"io"
+	"time"
+
+	"github.com/c-bata/go-prompt"
+)
+
 // OptionFunc changes the config for customization.
 type OptionFunc func(conf *Config)
 
+// WithTitle sets the title of the terminal window.
+func WithTitle(title string) OptionFunc {
+	return func(conf *Config) {
+		conf.Title = title
+	}
+}
+
+// WithPrefix sets the prompt prefix. The names of the entered scopes are shown before it.
+func WithPrefix(prefix string) OptionFunc {
+	return func(conf *Config) {
+		conf.Prefix = prefix
+	}
+}
+
+// WithPromptTemplate sets the template of the prompt prefix. See Environment.SetPromptTemplate.
+func WithPromptTemplate(text string) OptionFunc {
+	return func(conf *Config) {
+		conf.PromptTemplate = text
+	}
+}
+
+// WithMaxSuggestions sets the number of suggestions shown at once.
+func WithMaxSuggestions(n uint16) OptionFunc {
+	return func(conf *Config) {
+		conf.MaxSuggestions = n
+	}
+}
+
 // WithTitleScreen adds a title screen function which runs before the initial prompt.
 func WithTitleScreen(fn func()) OptionFunc {
 	return func(conf *Config) {
 		conf.TitleScreenFunc = fn
 	}
 }
+
+// WithStopOnError stops scripts at the first failing command.
+func WithStopOnError() OptionFunc {
+	return func(conf *Config) {
+		conf.StopOnError = true
+	}
+}
+
+// WithHistoryFile persists the command history to the given file instead of the DefaultHistoryFile
+// of the console name. An empty path keeps the history in memory.
+func WithHistoryFile(path string) OptionFunc {
+	return func(conf *Config) {
+		conf.HistoryFile = path
+	}
+}
+
+// WithHistorySize sets the maximum number of history entries.
+func WithHistorySize(size int) OptionFunc {
+	return func(conf *Config) {
+		conf.HistorySize = size
+	}
+}
+
+// WithExternalCommands runs unknown commands as programs on the host, e.g. `env | sort`.
+func WithExternalCommands() OptionFunc {
+	return func(conf *Config) {
+		conf.ExternalCommands = true
+	}
+}
+
+// WithConfigFile loads the env vars from the given file on startup if it exists. The file is also
+// the default for the save and load commands. See ConfigTypes for the supported formats.
+func WithConfigFile(path string) OptionFunc {
+	return func(conf *Config) {
+		conf.ConfigFile = path
+	}
+}
+
+// WithAutoSave saves the env vars to the config file when the console shuts down.
+func WithAutoSave() OptionFunc {
+	return func(conf *Config) {
+		conf.AutoSaveConfig = true
+	}
+}
+
+// WithMatcher sets how suggestions are matched against the word being typed, e.g. MatchPrefix or
+// MatchRanked. Commands can override it with Command.Matcher.
+func WithMatcher(matcher MatchFunc) OptionFunc {
+	return func(conf *Config) {
+		conf.Matcher = matcher
+	}
+}
+
+// WithColorScheme sets the colors of the prompt and the output, e.g. one of the bundled themes
+// returned by LookupTheme or a scheme read with LoadColorScheme.
+func WithColorScheme(scheme *ColorScheme) OptionFunc {
+	return func(conf *Config) {
+		conf.ColorScheme = scheme
+	}
+}
+
+// WithKeyBindings sets the key bindings of the prompt, see NewKeyBindings.
+func WithKeyBindings(bindings *KeyBindings) OptionFunc {
+	return func(conf *Config) {
+		conf.KeyBindings = bindings
+	}
+}
+
+// WithKeyBindingsFile reads the key bindings from a file when the console is created. See
+// LoadKeyBindings for the format.
+func WithKeyBindingsFile(path string) OptionFunc {
+	return func(conf *Config) {
+		conf.KeyBindingsFile = path
+	}
+}
+
+// WithKeyBinds adds key bindings to the prompt. They run in addition to the key bindings.
+func WithKeyBinds(binds ...prompt.KeyBind) OptionFunc {
+	return func(conf *Config) {
+		conf.KeyBinds = append(conf.KeyBinds, binds...)
+	}
+}
+
+// WithASCIICodeBinds adds bindings for raw input sequences to the prompt, e.g. meta key combinations.
+func WithASCIICodeBinds(binds ...prompt.ASCIICodeBind) OptionFunc {
+	return func(conf *Config) {
+		conf.ASCIICodeBinds = append(conf.ASCIICodeBinds, binds...)
+	}
+}
+
+// WithCompletionTimeout sets the deadline of the completion providers of the commands.
+func WithCompletionTimeout(timeout time.Duration) OptionFunc {
+	return func(conf *Config) {
+		conf.CompletionTimeout = timeout
+	}
+}
+
+// WithCompletionOnDown shows the suggestions when the down arrow is pressed.
+func WithCompletionOnDown() OptionFunc {
+	return func(conf *Config) {
+		conf.CompletionOnDown = true
+	}
+}
+
+// WithCompletionAtStart suggests the commands and scopes before anything is typed and on empty lines.
+func WithCompletionAtStart() OptionFunc {
+	return func(conf *Config) {
+		conf.ShowCompletionAtStart = true
+	}
+}
+
+// WithInput sets the input stream of the commands.
+func WithInput(r io.Reader) OptionFunc {
+	return func(conf *Config) {
+		conf.In = r
+	}
+}
+
+// WithOutput sets the output stream of the commands.
+func WithOutput(w io.Writer) OptionFunc {
+	return func(conf *Config) {
+		conf.Out = w
+	}
+}
+
+// WithErrorOutput sets the error stream of the commands, including the errors printed by the
+// console.
+func WithErrorOutput(w io.Writer) OptionFunc {
+	return func(conf *Config) {
+		conf.Err = w
+	}
+}
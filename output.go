@@ -0,0 +1,307 @@
+package console
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// OutputFormats are the formats accepted by the global output flag.
+var OutputFormats = []string{"table", "json", "yaml", "csv", "text"}
+
+// OutputKey is the env var holding the output format used when the output flag is not given.
+const OutputKey = "output_format"
+
+// outputFlags holds the global `--output`/`-o` flag. It can be given to any command which does not
+// define a flag with the same name or shorthand and is removed from the args before the command
+// parses its flags.
+var outputFlags = newOutputFlags()
+
+func newOutputFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("output", pflag.ContinueOnError)
+	flags.StringP("output", "o", "", "Output format: "+strings.Join(OutputFormats, ", "))
+	flags.SetAnnotation("output", Suggestions, OutputFormats)
+	return flags
+}
+
+// outputFlag returns the global output flag for the name or shorthand unless the command defines
+// the flag itself.
+func outputFlag(cmd *Command, name string, shorthand string) *pflag.Flag {
+	if cmd.Flags().Lookup("output") != nil {
+		return nil
+	}
+	if name == "output" || (shorthand == "o" && cmd.Flags().ShorthandLookup("o") == nil) {
+		return outputFlags.Lookup("output")
+	}
+	return nil
+}
+
+// parseOutputFlag removes the global output flag from the args of the command and returns the
+// format given with it. Args after `--` are not parsed.
+func parseOutputFlag(cmd *Command, args []string) ([]string, string, error) {
+	var (
+		rest   []string
+		format string
+	)
+
+	output := outputFlags.Lookup("output")
+	for index := 0; index < len(args); index++ {
+		word := args[index]
+		if word == "--" {
+			return append(rest, args[index:]...), format, nil
+		} else if !strings.HasPrefix(word, "-") || word == "-" {
+			rest = append(rest, word)
+			continue
+		}
+
+		// The value is given in the word, e.g. `--output=json` or `-ojson`, or as the next word
+		name, value, inline := word, "", false
+		if i := strings.Index(word, "="); i >= 0 {
+			name, value, inline = word[:i], word[i+1:], true
+		} else if !strings.HasPrefix(word, "--") && len(word) > 2 {
+			name, value, inline = word[:2], word[2:], true
+		}
+
+		if lookupFlag(cmd, name) == output {
+			if !inline {
+				if index+1 == len(args) {
+					return nil, "", fmt.Errorf("flag needs an argument: %s", word)
+				}
+				index++
+				value = args[index]
+			}
+			format = value
+			continue
+		}
+
+		// Keep the value of a flag of the command even if it looks like the output flag
+		rest = append(rest, word)
+		if valueFlag(cmd, word) != nil && index+1 < len(args) {
+			index++
+			rest = append(rest, args[index])
+		}
+	}
+	return rest, format, nil
+}
+
+// Table is tabular output. See Environment.Output.
+type Table struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// NewTable creates a table with the given columns.
+func NewTable(columns ...string) *Table {
+	return &Table{Columns: columns}
+}
+
+// AddRow adds a row. Values are formatted with FormatValue.
+func (t *Table) AddRow(values ...interface{}) {
+	t.Rows = append(t.Rows, values)
+}
+
+// records returns the rows as maps keyed by column, keeping the column order.
+func (t *Table) records() []yaml.MapSlice {
+	records := make([]yaml.MapSlice, len(t.Rows))
+	for index, row := range t.Rows {
+		record := make(yaml.MapSlice, len(t.Columns))
+		for column, name := range t.Columns {
+			record[column].Key = name
+			if column < len(row) {
+				record[column].Value = row[column]
+			}
+		}
+		records[index] = record
+	}
+	return records
+}
+
+// cells returns the formatted values of a row with one value per column.
+func (t *Table) cells(row []interface{}) []string {
+	cells := make([]string, len(t.Columns))
+	for column := range cells {
+		if column < len(row) {
+			cells[column] = FormatValue(row[column])
+		}
+	}
+	return cells
+}
+
+// Output writes structured data to the output of the command. The format is set with the global
+// `--output` flag or the env var named by OutputKey, see OutputFormats. Tables and maps are
+// written as aligned tables by default and other values as text.
+func (env *Environment) Output(v interface{}) error {
+	format := env.output
+	if format == "" {
+		format = FormatValue(env.Get(OutputKey))
+	}
+	return WriteOutput(env.Out, format, v)
+}
+
+// WriteOutput writes a value in the given format. Tables, maps and slices of maps are written as
+// tables in the table, csv and text formats.
+func WriteOutput(w io.Writer, format string, v interface{}) error {
+	table, isTable := toTable(v)
+	switch strings.ToLower(format) {
+	case "":
+		if isTable {
+			return writeTable(w, table)
+		}
+		return writeText(w, v)
+	case "table":
+		if !isTable {
+			return writeText(w, v)
+		}
+		return writeTable(w, table)
+	case "text":
+		if !isTable {
+			return writeText(w, v)
+		}
+		for _, row := range table.Rows {
+			fmt.Fprintln(w, strings.Join(table.cells(row), "\t"))
+		}
+		return nil
+	case "csv":
+		if !isTable {
+			return fmt.Errorf("csv output requires a table, got %T", v)
+		}
+		out := csv.NewWriter(w)
+		out.Write(table.Columns)
+		for _, row := range table.Rows {
+			out.Write(table.cells(row))
+		}
+		out.Flush()
+		return out.Error()
+	case "json":
+		if t, ok := v.(*Table); ok {
+			v = recordsJSON(t.records())
+		}
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case "yaml":
+		if t, ok := v.(*Table); ok {
+			v = t.records()
+		}
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	return fmt.Errorf("unknown output format %q, use one of %s", format, strings.Join(OutputFormats, ", "))
+}
+
+func writeTable(w io.Writer, table *Table) error {
+	out := tabwriter.NewWriter(w, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(out, strings.Join(table.Columns, "\t"))
+	for _, row := range table.Rows {
+		fmt.Fprintln(out, strings.Join(table.cells(row), "\t"))
+	}
+	return out.Flush()
+}
+
+func writeText(w io.Writer, v interface{}) error {
+	_, err := fmt.Fprintln(w, FormatValue(v))
+	return err
+}
+
+// toTable converts tables, maps and slices of maps to a table. Map keys are sorted.
+func toTable(v interface{}) (*Table, bool) {
+	switch val := v.(type) {
+	case *Table:
+		return val, true
+	case Table:
+		return &val, true
+	case map[string]interface{}:
+		table := NewTable("KEY", "VALUE")
+		for _, key := range sortedMapKeys(val) {
+			table.AddRow(key, val[key])
+		}
+		return table, true
+	case map[string]string:
+		table := NewTable("KEY", "VALUE")
+		for key, value := range val {
+			table.AddRow(key, value)
+		}
+		sort.Slice(table.Rows, func(i, j int) bool {
+			return table.Rows[i][0].(string) < table.Rows[j][0].(string)
+		})
+		return table, true
+	case []map[string]interface{}:
+		seen := map[string]bool{}
+		var columns []string
+		for _, record := range val {
+			for _, key := range sortedMapKeys(record) {
+				if !seen[key] {
+					seen[key] = true
+					columns = append(columns, key)
+				}
+			}
+		}
+
+		table := NewTable(columns...)
+		for _, record := range val {
+			row := make([]interface{}, len(columns))
+			for index, column := range columns {
+				row[index] = record[column]
+			}
+			table.AddRow(row...)
+		}
+		return table, true
+	}
+	return nil, false
+}
+
+func sortedMapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// recordsJSON converts records to JSON objects keeping the column order.
+type recordsJSON []yaml.MapSlice
+
+func (records recordsJSON) MarshalJSON() ([]byte, error) {
+	var buf strings.Builder
+	buf.WriteString("[")
+	for index, record := range records {
+		if index > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("{")
+		for field, item := range record {
+			key, err := json.Marshal(fmt.Sprint(item.Key))
+			if err != nil {
+				return nil, err
+			}
+			value, err := json.Marshal(item.Value)
+			if err != nil {
+				return nil, err
+			}
+			if field > 0 {
+				buf.WriteString(",")
+			}
+			buf.Write(key)
+			buf.WriteString(":")
+			buf.Write(value)
+		}
+		buf.WriteString("}")
+	}
+	buf.WriteString("]")
+	return []byte(buf.String()), nil
+}
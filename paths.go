@@ -0,0 +1,235 @@
+package console
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/c-bata/go-prompt"
+	"github.com/kballard/go-shellquote"
+	"github.com/spf13/pflag"
+)
+
+// FilePath and DirPath are the flag annotations enabling path completion. FilePath holds the
+// accepted file extensions, all files are suggested if it is empty.
+const (
+	FilePath = "file_path"
+	DirPath  = "dir_path"
+)
+
+// MarkFlagFilename enables file completion for a flag. Only files with one of the extensions, e.g.
+// "yaml", are suggested if extensions are given. Directories are always suggested.
+func (cmd *Command) MarkFlagFilename(name string, extensions ...string) error {
+	return cmd.Flags().SetAnnotation(name, FilePath, extensions)
+}
+
+// MarkFlagDirname enables directory completion for a flag.
+func (cmd *Command) MarkFlagDirname(name string) error {
+	return cmd.Flags().SetAnnotation(name, DirPath, []string{})
+}
+
+// flagPathFilter returns the path completion settings of a flag.
+func flagPathFilter(flag *pflag.Flag) (dirOnly bool, extensions []string, ok bool) {
+	if _, ok := flag.Annotations[DirPath]; ok {
+		return true, nil, true
+	}
+	if extensions, ok := flag.Annotations[FilePath]; ok {
+		return false, extensions, true
+	}
+	return false, nil, false
+}
+
+// ExpandHome replaces a leading ~ with the home directory of the user.
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return home + path[1:]
+}
+
+// completePath suggests the paths starting with the value being typed. word is the raw word being
+// typed, including quotes, and prefix the part of the word before the value, e.g. `--config=`. The
+// suggestions keep the quoting of the word and are relative to the text after the last space, which
+// is the part replaced by the prompt.
+func completePath(prefix string, word string, dirOnly bool, extensions []string) []prompt.Suggest {
+	raw := word[len(prefix):]
+	value, quote, ok := unquoteWord(raw)
+	if !ok {
+		return nil
+	}
+
+	dir, base := "", value
+	if index := strings.LastIndex(value, "/"); index >= 0 {
+		dir, base = value[:index+1], value[index+1:]
+	}
+
+	searchDir := ExpandHome(dir)
+	if value == "~" {
+		dir, base, searchDir = "~/", "", ExpandHome("~/")
+	} else if searchDir == "" {
+		searchDir = "."
+	}
+
+	entries, err := ioutil.ReadDir(searchDir)
+	if err != nil {
+		return nil
+	}
+
+	// The prompt replaces the text after the last space
+	replaced := word[:strings.LastIndex(word, " ")+1]
+
+	var suggestions []prompt.Suggest
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+
+		isDir := entry.IsDir()
+		if entry.Mode()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(searchDir, name)); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+
+		description := "directory"
+		if !isDir {
+			if dirOnly || !hasExtension(name, extensions) {
+				continue
+			}
+			description = "file"
+		}
+
+		path := dir + name
+		if isDir {
+			path += "/"
+		}
+
+		text := prefix + quoteWord(path, quote, !isDir)
+		if !strings.HasPrefix(text, replaced) {
+			continue
+		}
+		suggestions = append(suggestions, prompt.Suggest{Text: text[len(replaced):], Description: description})
+	}
+	return suggestions
+}
+
+func hasExtension(name string, extensions []string) bool {
+	if len(extensions) == 0 {
+		return true
+	}
+
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+	for _, allowed := range extensions {
+		if strings.EqualFold(ext, strings.TrimPrefix(allowed, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// unquoteWord removes the quotes and escapes of a raw word. The quote is the opening quote of the
+// word, if any. Returns false if the word ends in the middle of an escape sequence.
+func unquoteWord(raw string) (string, byte, bool) {
+	var (
+		buf     strings.Builder
+		opening byte
+		quote   byte
+		escaped bool
+	)
+	if raw != "" && (raw[0] == '"' || raw[0] == '\'') {
+		opening = raw[0]
+	}
+
+	for index := 0; index < len(raw); index++ {
+		ch := raw[index]
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\' && quote != '\'':
+			escaped = true
+			continue
+		case quote != 0 && ch == quote:
+			quote = 0
+			continue
+		case quote == 0 && (ch == '"' || ch == '\''):
+			quote = ch
+			continue
+		}
+		buf.WriteByte(ch)
+	}
+	return buf.String(), opening, !escaped
+}
+
+// quoteWord quotes a path so it is split into a single word. Paths are quoted with the given quote
+// or escaped with backslashes if quote is 0. The quote is closed if closed is true.
+func quoteWord(path string, quote byte, closed bool) string {
+	var buf strings.Builder
+	if quote != 0 {
+		buf.WriteByte(quote)
+	}
+
+	for index := 0; index < len(path); index++ {
+		ch := path[index]
+		switch {
+		case quote == '\'' && ch == '\'':
+			buf.WriteString(`'\''`)
+			continue
+		case quote == '"' && strings.IndexByte("\"\\$`", ch) >= 0:
+			buf.WriteByte('\\')
+		case quote == 0 && strings.IndexByte(" \t\"'\\$`&|;<>()*?!#", ch) >= 0:
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(ch)
+	}
+
+	if quote != 0 && closed {
+		buf.WriteByte(quote)
+	}
+	return buf.String()
+}
+
+// splitInput splits the input into words. An unterminated quote of the last word is closed.
+func splitInput(input string) ([]string, error) {
+	args, err := shellquote.Split(input)
+	if err == shellquote.UnterminatedDoubleQuoteError {
+		return shellquote.Split(input + `"`)
+	} else if err == shellquote.UnterminatedSingleQuoteError {
+		return shellquote.Split(input + `'`)
+	}
+	return args, err
+}
+
+// currentWord returns the raw word before the cursor including quotes and escapes. It is empty if a
+// new word is started.
+func currentWord(text string) string {
+	var (
+		start   int
+		quote   byte
+		escaped bool
+	)
+	for index := 0; index < len(text); index++ {
+		ch := text[index]
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			start = index + 1
+		}
+	}
+	return text[start:]
+}
@@ -0,0 +1,171 @@
+package console
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/kballard/go-shellquote"
+)
+
+// redirection is an output redirection at the end of a pipeline.
+type redirection struct {
+	path   string
+	append bool
+}
+
+// parsePipeline splits the input on unquoted pipes and an optional trailing output redirection.
+// Each stage is expanded and split into args with shellquote.
+func parsePipeline(input string, expand func(string) string) ([][]string, *redirection, error) {
+	var (
+		stages   [][]string
+		redirect *redirection
+		start    int
+		single   bool
+		double   bool
+		escaped  bool
+	)
+
+	addStage := func(end int) error {
+		args, err := shellquote.Split(expand(input[start:end]))
+		if err != nil {
+			return err
+		} else if len(args) == 0 {
+			return errors.New("empty command in pipeline")
+		}
+		stages = append(stages, args)
+		return nil
+	}
+
+	for index := 0; index < len(input); index++ {
+		ch := input[index]
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\' && !single:
+			escaped = true
+		case ch == '\'' && !double:
+			single = !single
+		case ch == '"' && !single:
+			double = !double
+		case single || double:
+		case ch == '|':
+			if err := addStage(index); err != nil {
+				return nil, nil, err
+			}
+			start = index + 1
+		case ch == '>':
+			if err := addStage(index); err != nil {
+				return nil, nil, err
+			}
+
+			redirect = &redirection{}
+			if index+1 < len(input) && input[index+1] == '>' {
+				redirect.append = true
+				index++
+			}
+
+			target, err := shellquote.Split(expand(input[index+1:]))
+			if err != nil {
+				return nil, nil, err
+			} else if len(target) != 1 {
+				return nil, nil, errors.New("redirection requires exactly one file")
+			}
+			redirect.path = target[0]
+			return stages, redirect, nil
+		}
+	}
+
+	if err := addStage(len(input)); err != nil {
+		return nil, nil, err
+	}
+	return stages, nil, nil
+}
+
+// executePipeline runs each stage in the current scope with the output of the previous stage as its
+// input. Stages run one after the other so the output of a stage is buffered until it completes.
+// The output of the last stage is written to the redirected file if given.
+func (env *Environment) executePipeline(stages [][]string, redirect *redirection) error {
+	in, out := env.In, env.Out
+	defer func() {
+		env.In, env.Out = in, out
+	}()
+
+	if redirect != nil {
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if redirect.append {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+
+		file, err := os.OpenFile(redirect.path, flags, 0644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	var input io.Reader = in
+	for index := 0; index < len(stages); index++ {
+		env.In = input
+		env.Out = out
+
+		var buf *bytes.Buffer
+		if index < len(stages)-1 {
+			buf = &bytes.Buffer{}
+			env.Out = buf
+		}
+
+		if err := env.executeStage(stages[index]); err != nil {
+			return err
+		}
+		input = buf
+	}
+	return nil
+}
+
+// executeStage executes the args in the current scope. Unknown commands are run as external
+// programs if enabled.
+func (env *Environment) executeStage(args []string) error {
+	scope := env.CurrentScope()
+	if scope == nil {
+		return errors.New("current scope is nil")
+	}
+
+	// The global output flag is parsed before the command parses its flags
+	if cmd := scope.lookupCommand(args); cmd != nil {
+		rest, format, err := parseOutputFlag(cmd, args)
+		if err != nil {
+			return err
+		}
+		if format != "" {
+			parent := env.output
+			env.output = format
+			defer func() {
+				env.output = parent
+			}()
+		}
+		args = rest
+	}
+
+	err := scope.Execute(env, args)
+	if errors.Is(err, ErrUnknownCommand) && env.ExternalCommands {
+		return env.executeExternal(args)
+	}
+	return err
+}
+
+// executeExternal runs a program on the host with the environment streams.
+func (env *Environment) executeExternal(args []string) error {
+	cmd := exec.CommandContext(env.Context(), args[0], args[1:]...)
+	cmd.Stdin = env.In
+	cmd.Stdout = env.Out
+	cmd.Stderr = env.Err
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package console
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		stages   [][]string
+		redirect *redirection
+		wantErr  bool
+	}{
+		{"single command", "echo a b", [][]string{{"echo", "a", "b"}}, nil, false},
+		{"pipe", "echo a | grep a", [][]string{{"echo", "a"}, {"grep", "a"}}, nil, false},
+		{"pipe without spaces", "a|b|c", [][]string{{"a"}, {"b"}, {"c"}}, nil, false},
+		{"pipe in double quotes", `echo "a|b" | wc`, [][]string{{"echo", "a|b"}, {"wc"}}, nil, false},
+		{"pipe in single quotes", "echo 'a | b'", [][]string{{"echo", "a | b"}}, nil, false},
+		{"escaped pipe", `echo a\|b`, [][]string{{"echo", "a|b"}}, nil, false},
+		{"redirect in quotes", `echo "a > b" '>>'`, [][]string{{"echo", "a > b", ">>"}}, nil, false},
+		{"escaped quote", `echo "a\"|b"`, [][]string{{"echo", `a"|b`}}, nil, false},
+		{"redirect", "echo a > out.txt", [][]string{{"echo", "a"}}, &redirection{path: "out.txt"}, false},
+		{"append", "echo a >> out.txt", [][]string{{"echo", "a"}}, &redirection{path: "out.txt", append: true}, false},
+		{"redirect after pipe", "a | b >'my file'", [][]string{{"a"}, {"b"}}, &redirection{path: "my file"}, false},
+		{"variable target", "echo a > $file", [][]string{{"echo", "a"}}, &redirection{path: "my out.txt"}, false},
+		{"variable with pipe", "echo $pipe", [][]string{{"echo", "a|b"}}, nil, false},
+		{"empty stage", "a || b", nil, nil, true},
+		{"trailing pipe", "a |", nil, nil, true},
+		{"leading pipe", "| a", nil, nil, true},
+		{"missing target", "echo a >", nil, nil, true},
+		{"two targets", "echo a > b c", nil, nil, true},
+		{"redirect without command", "> out.txt", nil, nil, true},
+		{"unterminated quote", `echo "a`, nil, nil, true},
+	}
+
+	env := NewEnvironment("> ")
+	env.Set("file", "my out.txt")
+	env.Set("pipe", "a|b")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, redirect, err := parsePipeline(tt.input, env.expandLine)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePipeline(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			} else if !reflect.DeepEqual(stages, tt.stages) {
+				t.Errorf("parsePipeline(%q) stages = %q, want %q", tt.input, stages, tt.stages)
+			} else if !reflect.DeepEqual(redirect, tt.redirect) {
+				t.Errorf("parsePipeline(%q) redirect = %+v, want %+v", tt.input, redirect, tt.redirect)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package console
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/c-bata/go-prompt"
+)
+
+// Segment markers in the rendered prefix. A color marker is followed by the color offset by
+// colorOffset. Control characters have no width so the prompt positions the cursor correctly.
+const (
+	colorMarker = '\x01'
+	resetMarker = '\x02'
+	colorOffset = 0x03
+)
+
+// PromptData is the data of the prompt template.
+type PromptData struct {
+	// Scopes are the names of the entered scopes starting with the root scope.
+	Scopes []string
+	Scope  string
+	Prefix string
+
+	// Vars are the env vars including the local vars of the current scope.
+	Vars map[string]interface{}
+
+	// Status is the exit status of the last command. It is 0 on success, 130 if the command was
+	// interrupted and 1 for other errors.
+	Status int
+	Failed bool
+
+	Time time.Time
+
+	// Jobs is the number of running background jobs.
+	Jobs int
+
+	// Mode is insert or normal in vi mode and empty in emacs mode.
+	Mode string
+}
+
+// SetPromptTemplate changes the prompt prefix to a text/template rendered with PromptData.
+//
+// Parts of the prefix can be colored with the color function, e.g.
+// `{{if .Failed}}{{color "red" "✗ "}}{{end}}{{index .Scopes 0}}[{{.Vars.env}}]> `. The rest of the
+// prefix uses the prefix color of the color scheme. Colors are named as in color scheme files. The
+// join function joins strings with a separator. The default prefix is shown while the template fails,
+// e.g. if it indexes a scope which is not entered.
+func (env *Environment) SetPromptTemplate(text string) error {
+	tmpl, err := template.New("prompt").Funcs(promptFuncs).Parse(text)
+	if err != nil {
+		return err
+	}
+	env.promptTemplate = tmpl
+	return nil
+}
+
+// LivePrefix allows for a dynamic prompt prefix. The prefix is rendered with the prompt template,
+// see SetPromptTemplate, or shows the scope names followed by the prefix, e.g. `mercator:binance> `.
+func (env *Environment) LivePrefix() (string, bool) {
+	data := env.promptData()
+	if env.promptTemplate != nil {
+		if prefix, err := renderPrompt(env.promptTemplate, data); err == nil {
+			return prefix, true
+		}
+	}
+	return strings.Join(data.Scopes, ":") + data.Prefix, true
+}
+
+// setStatus records the exit status of a command for the prompt.
+func (env *Environment) setStatus(err error) {
+	switch {
+	case err == nil:
+		env.status = 0
+	case errors.Is(err, ErrInterrupted):
+		env.status = 130
+	default:
+		env.status = 1
+	}
+}
+
+// Status returns the exit status of the last command entered at the prompt. See PromptData.
+func (env *Environment) Status() int {
+	return env.status
+}
+
+func (env *Environment) promptData() PromptData {
+	data := PromptData{
+		Prefix: env.Prefix,
+		Vars:   map[string]interface{}{},
+		Status: env.status,
+		Failed: env.status != 0,
+		Time:   time.Now(),
+		Mode:   env.editMode,
+	}
+	for _, scope := range env.ScopeStack {
+		data.Scopes = append(data.Scopes, scope.Name)
+	}
+	if len(data.Scopes) > 0 {
+		data.Scope = data.Scopes[len(data.Scopes)-1]
+	}
+	for _, key := range env.Keys() {
+		data.Vars[key] = env.Get(key)
+	}
+	for _, job := range env.Jobs() {
+		if !job.Done() {
+			data.Jobs++
+		}
+	}
+	return data
+}
+
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+	"color": func(name string, text interface{}) (string, error) {
+		c, ok := promptColors[normalizeColorName(name)]
+		if !ok {
+			return "", fmt.Errorf("unknown color %q", name)
+		}
+		return string([]byte{colorMarker, byte(c) + colorOffset}) + fmt.Sprint(text) + string(resetMarker), nil
+	},
+}
+
+func renderPrompt(tmpl *template.Template, data PromptData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// prefixWriter colors the segments of the prefix. The prompt writes the prefix as a single string
+// in the prefix color and strips escape sequences from it, so the color markers are replaced with
+// colors when the prefix is written.
+type prefixWriter struct {
+	prompt.ConsoleWriter
+	env *Environment
+}
+
+func (w prefixWriter) WriteStr(data string) {
+	for {
+		index := strings.IndexAny(data, string([]byte{colorMarker, resetMarker}))
+		if index < 0 {
+			break
+		}
+		w.ConsoleWriter.WriteStr(data[:index])
+
+		if data[index] == resetMarker {
+			w.ConsoleWriter.SetColor(w.env.colorScheme().PrefixTextColor, prompt.DefaultColor, false)
+			data = data[index+1:]
+			continue
+		}
+		if index+1 < len(data) {
+			w.ConsoleWriter.SetColor(prompt.Color(data[index+1]-colorOffset), prompt.DefaultColor, false)
+		}
+		data = data[minInt(index+2, len(data)):]
+	}
+	w.ConsoleWriter.WriteStr(data)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -5,8 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 )
 
+// ErrUnknownCommand is returned when a command does not exist in a scope.
+var ErrUnknownCommand = errors.New("unknown command")
+
 // NewScope creates a new scope.
 func NewScope(name string, description string) *Scope {
 	scope := &Scope{
@@ -20,8 +24,12 @@ func NewScope(name string, description string) *Scope {
 	scope.AddCommand(&Command{
 		Use:   "use",
 		Short: "Use pushes a new scope onto the environment",
-		Suggestions: func(env *Environment, args []string) []string {
-			return scope.AvailableScopes()
+		RichSuggestions: func(env *Environment, args []string) []Suggestion {
+			var suggestions []Suggestion
+			for _, name := range scope.AvailableScopes() {
+				suggestions = append(suggestions, Suggestion{Text: name, Description: scope.subScopes[name].Description})
+			}
+			return suggestions
 		},
 		EagerSuggestions: true,
 		Run: func(env *Environment, cmd *Command, args []string) error {
@@ -45,29 +53,43 @@ func NewScope(name string, description string) *Scope {
 	scope.AddCommand(&Command{
 		Use:   "help",
 		Short: "Prints help info",
-		Suggestions: func(env *Environment, args []string) []string {
-			return scope.AvailableCommands()
+		RichSuggestions: func(env *Environment, args []string) []Suggestion {
+			var suggestions []Suggestion
+			for _, name := range scope.AvailableCommands() {
+				sug := Suggestion{Text: name, Description: scope.commands[name].Short}
+				if scope.commands[name].IsBuiltIn {
+					sug.Group = "built-in"
+				}
+				suggestions = append(suggestions, sug)
+			}
+			return suggestions
 		},
 		EagerSuggestions: true,
 		Run: func(env *Environment, cmd *Command, args []string) error {
-			if len(args) > 1 {
-				return errors.New("help accepts only 1 argument")
-			} else if len(args) == 1 {
+			if len(args) > 0 {
 				cmd, ok := scope.commands[args[0]]
 				if ok {
-					fmt.Println(cmd.Usage())
+					sub, n := cmd.findCommand(args[1:])
+					if n != len(args)-1 {
+						return errors.New("unknown argument")
+					}
+					fmt.Fprintln(env.Out, sub.usage(env))
 					return nil
 				}
 
+				if len(args) > 1 {
+					return errors.New("help accepts only 1 argument")
+				}
+
 				sub, ok := scope.subScopes[args[0]]
 				if ok {
-					fmt.Println(sub.Usage())
+					fmt.Fprintln(env.Out, sub.Usage())
 					return nil
 				}
 				return errors.New("unknown argument")
 			}
 
-			fmt.Println(scope.Usage())
+			fmt.Fprintln(env.Out, scope.Usage())
 			return nil
 		},
 		IsBuiltIn: true,
@@ -78,8 +100,11 @@ func NewScope(name string, description string) *Scope {
 
 // Scope represents related commands
 type Scope struct {
-	Name           string
-	Description    string
+	Name        string
+	Description string
+
+	// InitializeFunc runs each time the scope is pushed onto the environment. This includes commands
+	// executed in the scope from its parent, e.g. `binance risk`.
 	InitializeFunc func(*Environment)
 
 	commands  map[string]*Command
@@ -98,16 +123,7 @@ func (s *Scope) SubScopes() map[string]*Scope {
 
 // AddCommand adds a command to the scope.
 func (s *Scope) AddCommand(cmd *Command) {
-	if cmd.Suggestions == nil {
-		cmd.Suggestions = func(*Environment, []string) []string { return nil }
-	}
-
-	// Add help flag
-	helpFlag := cmd.Flags().Lookup("help")
-	if helpFlag == nil {
-		cmd.Flags().BoolP("help", "h", false, "Prints this help")
-		cmd.Flags().Lookup("help").Hidden = true
-	}
+	initCommand(cmd)
 	s.commands[cmd.Use] = cmd
 
 	for _, alias := range cmd.Aliases {
@@ -152,20 +168,71 @@ func (s *Scope) AvailableScopes() []string {
 	return scopes
 }
 
-// Execute args in a scope.
+// Execute args in a scope. Commands in sub-scopes can be executed without entering the scope with
+// `<scope> <command>` or `<scope>:<command>`. The sub-scope is pushed for the duration of the
+// command and the scope stack is restored afterwards, which also removes the local variables set by
+// the command. Commands which enter or exit a scope, e.g. `binance use account`, fail in this form
+// as their scope change would be undone.
 func (s *Scope) Execute(env *Environment, args []string) error {
 	if len(args) == 0 {
 		return errors.New("no command given")
 	}
 
-	// Execute command
+	// Execute command or sub-command
 	if cmd, ok := s.commands[args[0]]; ok {
-		if len(args) > 0 {
-			return cmd.Execute(env, args[1:])
+		cmd, n := cmd.findCommand(args[1:])
+		return cmd.Execute(env, args[1+n:])
+	}
+
+	// Execute in a sub-scope
+	if sub, rest, ok := s.findSubScope(args); ok {
+		if len(rest) == 0 {
+			return fmt.Errorf("'%s' is a scope, run 'use %s' to enter it", sub.Name, sub.Name)
+		}
+
+		depth := env.Len()
+		env.Push(sub)
+		defer env.truncate(depth)
+		if err := sub.Execute(env, rest); err != nil {
+			return err
 		}
-		return cmd.Execute(env, nil)
+
+		if env.Len() != depth+1 || env.CurrentScope() != sub {
+			return fmt.Errorf("'%s' changes the scope and can not run as '%s %s', run 'use %s' first",
+				rest[0], sub.Name, strings.Join(rest, " "), sub.Name)
+		}
+		return nil
+	}
+	return ErrUnknownCommand
+}
+
+// lookupCommand returns the command or sub-command run by the args, including the commands of
+// sub-scopes. Returns nil for unknown commands.
+func (s *Scope) lookupCommand(args []string) *Command {
+	if len(args) == 0 {
+		return nil
+	}
+
+	if cmd, ok := s.commands[args[0]]; ok {
+		cmd, _ = cmd.findCommand(args[1:])
+		return cmd
 	}
-	return errors.New("unknown command")
+	if sub, rest, ok := s.findSubScope(args); ok {
+		return sub.lookupCommand(rest)
+	}
+	return nil
+}
+
+// findSubScope returns the sub-scope named by the first arg and the remaining args. The first arg
+// may be qualified with a colon, e.g. `binance:risk`.
+func (s *Scope) findSubScope(args []string) (*Scope, []string, bool) {
+	name, rest := args[0], args[1:]
+	if index := strings.Index(name, ":"); index >= 0 {
+		name, rest = name[:index], append([]string{name[index+1:]}, rest...)
+	}
+
+	sub, ok := s.subScopes[name]
+	return sub, rest, ok
 }
 
 // Usage returns the scope usage.
@@ -0,0 +1,106 @@
+package console
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ScriptError is returned when a line of a script fails to execute.
+type ScriptError struct {
+	Line int
+	Err  error
+}
+
+// Error returns the error message prefixed with the failing line number.
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err.Error())
+}
+
+// Unwrap returns the underlying error.
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// RunScript executes each line read from r as if it was entered at the prompt. Blank lines and
+// lines starting with '#' are ignored and a trailing backslash continues the command on the next
+// line. Scope changes made by the script persist after it has finished and the script stops once
+// the environment has been asked to exit.
+//
+// If stopOnError is true, execution stops at the first failing line and its error is returned.
+// Otherwise failing lines are reported and the first error is returned once the script completes.
+func (env *Environment) RunScript(r io.Reader, stopOnError bool) error {
+	var (
+		buf       strings.Builder
+		lineNum   int
+		startLine int
+		firstErr  error
+	)
+
+	execute := func() error {
+		input := buf.String()
+		buf.Reset()
+
+		if err := env.ExecuteLine(input); err != nil {
+			err = &ScriptError{Line: startLine, Err: err}
+			if stopOnError {
+				return err
+			}
+
+			env.PrintError(err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+
+		if buf.Len() == 0 {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			startLine = lineNum
+		}
+
+		// Line continuation
+		if hasContinuation(line) {
+			buf.WriteString(line[:len(line)-1])
+			continue
+		}
+		buf.WriteString(line)
+
+		if err := execute(); err != nil {
+			return err
+		}
+		if env.Exited() {
+			return firstErr
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Execute a dangling continuation
+	if buf.Len() > 0 {
+		if err := execute(); err != nil {
+			return err
+		}
+	}
+	return firstErr
+}
+
+// hasContinuation returns true if the line ends with an unescaped backslash.
+func hasContinuation(line string) bool {
+	var count int
+	for index := len(line) - 1; index >= 0 && line[index] == '\\'; index-- {
+		count++
+	}
+	return count%2 == 1
+}
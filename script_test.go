@@ -0,0 +1,28 @@
+package console
+
+import "testing"
+
+func TestHasContinuation(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want bool
+	}{
+		{"empty", "", false},
+		{"no backslash", "echo a", false},
+		{"backslash", `echo a \`, true},
+		{"only backslash", `\`, true},
+		{"escaped backslash", `echo a \\`, false},
+		{"escaped backslash and continuation", `echo a \\\`, true},
+		{"backslash inside", `echo a\ b`, false},
+		{"trailing space", `echo a \ `, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasContinuation(tt.line); got != tt.want {
+				t.Errorf("hasContinuation(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
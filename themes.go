@@ -0,0 +1,348 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/c-bata/go-prompt"
+	"github.com/gookit/color"
+	"github.com/spf13/viper"
+)
+
+var themes = map[string]*ColorScheme{
+	"default": DefaultColorScheme,
+	"dark": {
+		ScrollbarThumbColor:          prompt.DarkGray,
+		ScrollbarBGColor:             prompt.Black,
+		PrefixTextColor:              prompt.Turquoise,
+		InputTextColor:               prompt.White,
+		DescriptionBGColor:           prompt.DarkGray,
+		DescriptionTextColor:         prompt.White,
+		SuggestionBGColor:            prompt.Black,
+		SuggestionTextColor:          prompt.White,
+		SelectedSuggestionBGColor:    prompt.Turquoise,
+		SelectedSuggestionTextColor:  prompt.Black,
+		SelectedDescriptionBGColor:   prompt.Cyan,
+		SelectedDescriptionTextColor: prompt.Black,
+		InfoLabelColor:               color.Style{color.FgLightCyan},
+		ErrorColor:                   color.Style{color.FgLightRed},
+		WarningColor:                 color.Style{color.FgLightYellow},
+	},
+	"light": {
+		ScrollbarThumbColor:          prompt.DarkGray,
+		ScrollbarBGColor:             prompt.LightGray,
+		PrefixTextColor:              prompt.DarkBlue,
+		InputTextColor:               prompt.Black,
+		DescriptionBGColor:           prompt.LightGray,
+		DescriptionTextColor:         prompt.Black,
+		SuggestionBGColor:            prompt.White,
+		SuggestionTextColor:          prompt.Black,
+		SelectedSuggestionBGColor:    prompt.DarkBlue,
+		SelectedSuggestionTextColor:  prompt.White,
+		SelectedDescriptionBGColor:   prompt.Blue,
+		SelectedDescriptionTextColor: prompt.White,
+		InfoLabelColor:               color.Style{color.FgBlue},
+		ErrorColor:                   color.Style{color.OpBold, color.FgRed},
+		WarningColor:                 color.Style{color.FgMagenta},
+	},
+	"solarized": {
+		ScrollbarThumbColor:          prompt.Turquoise,
+		ScrollbarBGColor:             prompt.DarkGray,
+		PrefixTextColor:              prompt.Brown,
+		InputTextColor:               prompt.LightGray,
+		DescriptionBGColor:           prompt.DarkGray,
+		DescriptionTextColor:         prompt.LightGray,
+		SuggestionBGColor:            prompt.Black,
+		SuggestionTextColor:          prompt.LightGray,
+		SelectedSuggestionBGColor:    prompt.DarkBlue,
+		SelectedSuggestionTextColor:  prompt.White,
+		SelectedDescriptionBGColor:   prompt.Blue,
+		SelectedDescriptionTextColor: prompt.White,
+		InfoLabelColor:               color.Style{color.FgCyan},
+		ErrorColor:                   color.Style{color.FgRed},
+		WarningColor:                 color.Style{color.FgYellow},
+	},
+	"high-contrast": {
+		ScrollbarThumbColor:          prompt.Yellow,
+		ScrollbarBGColor:             prompt.Black,
+		PrefixTextColor:              prompt.Yellow,
+		InputTextColor:               prompt.White,
+		DescriptionBGColor:           prompt.Black,
+		DescriptionTextColor:         prompt.Yellow,
+		SuggestionBGColor:            prompt.Black,
+		SuggestionTextColor:          prompt.White,
+		SelectedSuggestionBGColor:    prompt.Yellow,
+		SelectedSuggestionTextColor:  prompt.Black,
+		SelectedDescriptionBGColor:   prompt.White,
+		SelectedDescriptionTextColor: prompt.Black,
+		InfoLabelColor:               color.Style{color.OpBold, color.FgLightYellow},
+		ErrorColor:                   color.Style{color.OpBold, color.FgLightWhite, color.BgRed},
+		WarningColor:                 color.Style{color.OpBold, color.FgLightYellow},
+	},
+	"monochrome": {
+		ScrollbarThumbColor:          prompt.DarkGray,
+		ScrollbarBGColor:             prompt.LightGray,
+		PrefixTextColor:              prompt.DefaultColor,
+		InputTextColor:               prompt.DefaultColor,
+		DescriptionBGColor:           prompt.LightGray,
+		DescriptionTextColor:         prompt.Black,
+		SuggestionBGColor:            prompt.LightGray,
+		SuggestionTextColor:          prompt.Black,
+		SelectedSuggestionBGColor:    prompt.DarkGray,
+		SelectedSuggestionTextColor:  prompt.White,
+		SelectedDescriptionBGColor:   prompt.DarkGray,
+		SelectedDescriptionTextColor: prompt.White,
+		InfoLabelColor:               color.Style{},
+		ErrorColor:                   color.Style{},
+		WarningColor:                 color.Style{},
+	},
+}
+
+// RegisterTheme adds a named color scheme which can be selected with the theme command.
+func RegisterTheme(name string, scheme *ColorScheme) {
+	themes[name] = scheme
+}
+
+// LookupTheme returns a registered color scheme. The bundled themes are default, dark, light,
+// solarized, high-contrast and monochrome.
+func LookupTheme(name string) (*ColorScheme, bool) {
+	scheme, ok := themes[name]
+	return scheme, ok
+}
+
+// ThemeNames returns the sorted names of the registered themes.
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// themeName returns the name of a registered color scheme.
+func themeName(scheme *ColorScheme) (string, bool) {
+	for name, theme := range themes {
+		if theme == scheme {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// promptColors are the names of the prompt colors used in color scheme files.
+var promptColors = map[string]prompt.Color{
+	"default":   prompt.DefaultColor,
+	"black":     prompt.Black,
+	"darkred":   prompt.DarkRed,
+	"darkgreen": prompt.DarkGreen,
+	"brown":     prompt.Brown,
+	"darkblue":  prompt.DarkBlue,
+	"purple":    prompt.Purple,
+	"cyan":      prompt.Cyan,
+	"lightgray": prompt.LightGray,
+	"darkgray":  prompt.DarkGray,
+	"red":       prompt.Red,
+	"green":     prompt.Green,
+	"yellow":    prompt.Yellow,
+	"blue":      prompt.Blue,
+	"fuchsia":   prompt.Fuchsia,
+	"turquoise": prompt.Turquoise,
+	"white":     prompt.White,
+}
+
+// LoadColorScheme reads a color scheme from a yaml, json or toml file. Colors are given by name,
+// e.g. `prefix_text: turquoise`, and output colors as a list of styles and colors with an optional
+// background, e.g. `error: bold lightWhite on red`. Missing colors are taken from the theme named
+// by the `base` key or the default scheme.
+//
+// The keys are scrollbar_thumb, scrollbar_bg, prefix_text, input_text, description_bg,
+// description_text, suggestion_bg, suggestion_text, selected_suggestion_bg,
+// selected_suggestion_text, selected_description_bg, selected_description_text, info_label, error
+// and warning.
+func LoadColorScheme(path string) (*ColorScheme, error) {
+	configType, err := configType(path)
+	if err != nil {
+		return nil, err
+	}
+
+	in := viper.New()
+	in.SetConfigFile(path)
+	in.SetConfigType(configType)
+	if err := in.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	base := DefaultColorScheme
+	if name := in.GetString("base"); name != "" {
+		var ok bool
+		if base, ok = LookupTheme(name); !ok {
+			return nil, fmt.Errorf("unknown theme: %s", name)
+		}
+	}
+	scheme := *base
+
+	colors, styles := schemeColors(&scheme), schemeStyles(&scheme)
+	for _, key := range in.AllKeys() {
+		value := in.GetString(key)
+		if key == "base" {
+			continue
+		} else if field, ok := colors[key]; ok {
+			c, ok := promptColors[normalizeColorName(value)]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown color %q", key, value)
+			}
+			*field = c
+		} else if field, ok := styles[key]; ok {
+			style, err := parseStyle(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			*field = style
+		} else {
+			return nil, fmt.Errorf("unknown color key: %s", key)
+		}
+	}
+	return &scheme, nil
+}
+
+func schemeColors(scheme *ColorScheme) map[string]*prompt.Color {
+	return map[string]*prompt.Color{
+		"scrollbar_thumb":           &scheme.ScrollbarThumbColor,
+		"scrollbar_bg":              &scheme.ScrollbarBGColor,
+		"prefix_text":               &scheme.PrefixTextColor,
+		"input_text":                &scheme.InputTextColor,
+		"description_bg":            &scheme.DescriptionBGColor,
+		"description_text":          &scheme.DescriptionTextColor,
+		"suggestion_bg":             &scheme.SuggestionBGColor,
+		"suggestion_text":           &scheme.SuggestionTextColor,
+		"selected_suggestion_bg":    &scheme.SelectedSuggestionBGColor,
+		"selected_suggestion_text":  &scheme.SelectedSuggestionTextColor,
+		"selected_description_bg":   &scheme.SelectedDescriptionBGColor,
+		"selected_description_text": &scheme.SelectedDescriptionTextColor,
+	}
+}
+
+func schemeStyles(scheme *ColorScheme) map[string]*color.Style {
+	return map[string]*color.Style{
+		"info_label": &scheme.InfoLabelColor,
+		"error":      &scheme.ErrorColor,
+		"warning":    &scheme.WarningColor,
+	}
+}
+
+// normalizeColorName lowercases a color name and removes separators, e.g. `dark_gray` is darkgray.
+func normalizeColorName(name string) string {
+	return strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(name))
+}
+
+// parseStyle parses an output style, e.g. `bold lightWhite on red`. `none` is the empty style.
+func parseStyle(value string) (color.Style, error) {
+	style := color.Style{}
+	words := strings.Fields(value)
+	for index := 0; index < len(words); index++ {
+		word := words[index]
+		if strings.EqualFold(word, "none") {
+			continue
+		}
+
+		if strings.EqualFold(word, "on") {
+			if index++; index == len(words) {
+				return nil, errors.New("missing background color")
+			}
+			c, ok := lookupColor(words[index], color.BgColors, color.ExBgColors)
+			if !ok {
+				return nil, fmt.Errorf("unknown background color %q", words[index])
+			}
+			style = append(style, c)
+			continue
+		}
+
+		c, ok := lookupColor(word, color.AllOptions, color.FgColors, color.ExFgColors)
+		if !ok {
+			return nil, fmt.Errorf("unknown color %q", word)
+		}
+		style = append(style, c)
+	}
+	return style, nil
+}
+
+func lookupColor(name string, maps ...map[string]color.Color) (color.Color, bool) {
+	name = normalizeColorName(name)
+	for _, m := range maps {
+		for key, c := range m {
+			if strings.ToLower(key) == name {
+				return c, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func addThemeCommands(scope *Scope) {
+	theme := &Command{
+		Use:   "theme",
+		Short: "Lists the themes or switches to a theme",
+		Long:  "Color schemes can be loaded from a yaml, json or toml file with --file.",
+		Args: []Arg{
+			{Name: "name", Description: "Theme to switch to", Optional: true},
+		},
+		EagerSuggestions: true,
+		RichSuggestions: func(env *Environment, args []string) []Suggestion {
+			current, _ := themeName(env.colorScheme())
+			var suggestions []Suggestion
+			for _, name := range ThemeNames() {
+				sug := Suggestion{Text: name}
+				if name == current {
+					sug.Description = "current"
+				}
+				suggestions = append(suggestions, sug)
+			}
+			return suggestions
+		},
+		Run: func(env *Environment, cmd *Command, args []string) error {
+			path, err := cmd.Flags().GetString("file")
+			if err != nil {
+				return err
+			}
+
+			if path != "" {
+				if len(args) > 0 {
+					return errors.New("accepts either a theme or a file")
+				}
+				scheme, err := LoadColorScheme(path)
+				if err != nil {
+					return err
+				}
+				env.SetColorScheme(scheme)
+				return nil
+			}
+
+			if len(args) == 1 {
+				scheme, ok := LookupTheme(args[0])
+				if !ok {
+					return fmt.Errorf("unknown theme: %s", args[0])
+				}
+				env.SetColorScheme(scheme)
+				return nil
+			}
+
+			current, _ := themeName(env.colorScheme())
+			for _, name := range ThemeNames() {
+				if name == current {
+					fmt.Fprintf(env.Out, "* %s\n", name)
+				} else {
+					fmt.Fprintf(env.Out, "  %s\n", name)
+				}
+			}
+			return nil
+		},
+		IsBuiltIn:       true,
+		ShouldPropagate: true,
+	}
+	theme.Flags().StringP("file", "f", "", "Loads the color scheme from a file")
+	theme.MarkFlagFilename("file", "yaml", "yml", "json", "toml")
+	scope.AddCommand(theme)
+}
@@ -1,6 +1,15 @@
 package console
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // ValidationFunc validates input args
 type ValidationFunc func([]string) error
@@ -46,3 +55,203 @@ func CombineValidation(validators ...ValidationFunc) ValidationFunc {
 		return nil
 	}
 }
+
+// ArgError is returned by the validators when an argument or flag value is invalid.
+type ArgError struct {
+	// Index is the position of the argument. Name is the name of the argument in the arg spec and
+	// Flag is the name of the flag if a flag value is invalid.
+	Index int
+	Name  string
+	Flag  string
+	Value string
+	Err   error
+}
+
+func (e *ArgError) Error() string {
+	switch {
+	case e.Flag != "":
+		return fmt.Sprintf("invalid value %q for --%s: %v", e.Value, e.Flag, e.Err)
+	case e.Name != "":
+		return fmt.Sprintf("invalid value %q for <%s>: %v", e.Value, e.Name, e.Err)
+	}
+	return fmt.Sprintf("invalid value %q for argument %d: %v", e.Value, e.Index+1, e.Err)
+}
+
+func (e *ArgError) Unwrap() error {
+	return e.Err
+}
+
+// eachArg validates every argument with fn.
+func eachArg(fn func(string) error) ValidationFunc {
+	return func(args []string) error {
+		for index, arg := range args {
+			if err := fn(arg); err != nil {
+				return &ArgError{Index: index, Value: arg, Err: err}
+			}
+		}
+		return nil
+	}
+}
+
+// OneOf validates that every argument is one of the values
+func OneOf(values ...string) ValidationFunc {
+	return eachArg(func(arg string) error {
+		for _, value := range values {
+			if arg == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+	})
+}
+
+// MatchesRegexp validates that every argument matches the regular expression. Panics if the
+// expression is invalid.
+func MatchesRegexp(expr string) ValidationFunc {
+	pattern := regexp.MustCompile(expr)
+	return eachArg(func(arg string) error {
+		if !pattern.MatchString(arg) {
+			return fmt.Errorf("must match %s", expr)
+		}
+		return nil
+	})
+}
+
+// IntRange validates that every argument is an integer between min and max, inclusive
+func IntRange(min, max int) ValidationFunc {
+	return eachArg(func(arg string) error {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < min || n > max {
+			return fmt.Errorf("must be an integer between %d and %d", min, max)
+		}
+		return nil
+	})
+}
+
+// FloatRange validates that every argument is a number between min and max, inclusive
+func FloatRange(min, max float64) ValidationFunc {
+	return eachArg(func(arg string) error {
+		f, err := strconv.ParseFloat(arg, 64)
+		if err != nil || f < min || f > max {
+			return fmt.Errorf("must be a number between %v and %v", min, max)
+		}
+		return nil
+	})
+}
+
+// FileExists validates that every argument is an existing file
+func FileExists(args []string) error {
+	return eachArg(func(arg string) error {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return errors.New("file does not exist")
+		} else if info.IsDir() {
+			return errors.New("is a directory")
+		}
+		return nil
+	})(args)
+}
+
+// DirExists validates that every argument is an existing directory
+func DirExists(args []string) error {
+	return eachArg(func(arg string) error {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return errors.New("directory does not exist")
+		} else if !info.IsDir() {
+			return errors.New("is not a directory")
+		}
+		return nil
+	})(args)
+}
+
+// ValidURL validates that every argument is an absolute URL
+func ValidURL(args []string) error {
+	return eachArg(func(arg string) error {
+		u, err := url.Parse(arg)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.New("must be an absolute URL")
+		}
+		return nil
+	})(args)
+}
+
+// ValidDuration validates that every argument is a duration, e.g. 1m30s
+func ValidDuration(args []string) error {
+	return eachArg(func(arg string) error {
+		if _, err := time.ParseDuration(arg); err != nil {
+			return errors.New("must be a duration, e.g. 1m30s")
+		}
+		return nil
+	})(args)
+}
+
+// ArgAt validates only the argument at index i. Missing arguments are not validated.
+func ArgAt(i int, fn ValidationFunc) ValidationFunc {
+	return func(args []string) error {
+		if i >= len(args) {
+			return nil
+		}
+
+		err := fn(args[i : i+1])
+		var argErr *ArgError
+		if errors.As(err, &argErr) {
+			return &ArgError{Index: i, Value: argErr.Value, Err: argErr.Err}
+		} else if err != nil {
+			return &ArgError{Index: i, Value: args[i], Err: err}
+		}
+		return nil
+	}
+}
+
+// Or validates command args against several validators and passes if any of them passes
+func Or(validators ...ValidationFunc) ValidationFunc {
+	return func(args []string) error {
+		var errs []error
+		for _, fn := range validators {
+			err := fn(args)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err)
+		}
+		if len(errs) == 0 {
+			return nil
+		}
+
+		// Combine the reasons if all the validators rejected the same argument
+		var first *ArgError
+		reasons := make([]string, len(errs))
+		for index, err := range errs {
+			var argErr *ArgError
+			if !errors.As(err, &argErr) || (first != nil && argErr.Index != first.Index) {
+				first = nil
+				break
+			}
+			if first == nil {
+				first = argErr
+			}
+			reasons[index] = argErr.Err.Error()
+		}
+		if first != nil {
+			return &ArgError{Index: first.Index, Value: first.Value, Err: errors.New(strings.Join(reasons, " or "))}
+		}
+
+		for index, err := range errs {
+			reasons[index] = err.Error()
+		}
+		return errors.New(strings.Join(reasons, " or "))
+	}
+}
+
+// Not validates every argument against fn and fails for the arguments fn accepts
+func Not(fn ValidationFunc) ValidationFunc {
+	return func(args []string) error {
+		for index, arg := range args {
+			if fn([]string{arg}) == nil {
+				return &ArgError{Index: index, Value: arg, Err: errors.New("is not allowed")}
+			}
+		}
+		return nil
+	}
+}
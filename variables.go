@@ -0,0 +1,366 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+)
+
+// VariableTypes are the value types accepted by `set --type`.
+var VariableTypes = []string{"string", "int", "float", "bool", "duration", "list"}
+
+// ParseValue converts a string to a variable of the given type. Lists are comma separated.
+func ParseValue(typ string, value string) (interface{}, error) {
+	switch strings.ToLower(typ) {
+	case "", "string":
+		return value, nil
+	case "int":
+		return strconv.Atoi(value)
+	case "float":
+		return strconv.ParseFloat(value, 64)
+	case "bool":
+		return strconv.ParseBool(value)
+	case "duration":
+		return time.ParseDuration(value)
+	case "list":
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("unknown type: %s", typ)
+}
+
+// FormatValue converts a variable to the string used when it is expanded. Lists are comma separated.
+func FormatValue(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(val, ",")
+	case []interface{}:
+		items := make([]string, len(val))
+		for index := range val {
+			items[index] = fmt.Sprint(val[index])
+		}
+		return strings.Join(items, ",")
+	case time.Duration:
+		return val.String()
+	}
+	return fmt.Sprint(value)
+}
+
+// Set sets a global variable.
+func (env *Environment) Set(key string, value interface{}) {
+	env.shared.mu.Lock()
+	defer env.shared.mu.Unlock()
+	env.Configuration.Set(key, value)
+}
+
+// SetLocal sets a variable in the current scope. Local variables shadow variables of the same name
+// in parent scopes and are removed when the scope is popped. Variables set in the root scope are
+// global.
+func (env *Environment) SetLocal(key string, value interface{}) {
+	depth := env.Len() - 1
+	if depth <= 0 {
+		env.Set(key, value)
+		return
+	}
+
+	if env.locals == nil {
+		env.locals = map[int]map[string]interface{}{}
+	}
+	if env.locals[depth] == nil {
+		env.locals[depth] = map[string]interface{}{}
+	}
+	env.locals[depth][strings.ToLower(key)] = value
+}
+
+// Lookup returns a variable and whether it is set. The innermost scope defining the variable wins
+// before falling back to the global variables.
+func (env *Environment) Lookup(key string) (interface{}, bool) {
+	key = strings.ToLower(key)
+	for depth := env.Len() - 1; depth > 0; depth-- {
+		if value, ok := env.locals[depth][key]; ok {
+			return value, true
+		}
+	}
+
+	env.shared.mu.Lock()
+	defer env.shared.mu.Unlock()
+	value := env.Configuration.Get(key)
+	return value, value != nil
+}
+
+// Get returns a variable. Returns nil if it is not set.
+func (env *Environment) Get(key string) interface{} {
+	value, _ := env.Lookup(key)
+	return value
+}
+
+// IsLocal returns true if the variable is defined in the current scope or one of its parents
+// rather than globally.
+func (env *Environment) IsLocal(key string) bool {
+	key = strings.ToLower(key)
+	for depth := env.Len() - 1; depth > 0; depth-- {
+		if _, ok := env.locals[depth][key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Unset removes the innermost definition of a variable.
+func (env *Environment) Unset(key string) error {
+	key = strings.ToLower(key)
+	for depth := env.Len() - 1; depth > 0; depth-- {
+		if _, ok := env.locals[depth][key]; ok {
+			delete(env.locals[depth], key)
+			return nil
+		}
+	}
+
+	env.shared.mu.Lock()
+	defer env.shared.mu.Unlock()
+	if !env.Configuration.IsSet(key) {
+		return fmt.Errorf("%s is not set", key)
+	}
+
+	// viper can not remove keys so the value is cleared instead
+	env.Configuration.Set(key, nil)
+	return nil
+}
+
+// Keys returns the sorted names of all the variables visible in the current scope.
+func (env *Environment) Keys() []string {
+	seen := map[string]bool{}
+	env.shared.mu.Lock()
+	for _, key := range env.Configuration.AllKeys() {
+		if env.Configuration.Get(key) != nil {
+			seen[key] = true
+		}
+	}
+	env.shared.mu.Unlock()
+
+	for depth := env.Len() - 1; depth > 0; depth-- {
+		for key := range env.locals[depth] {
+			seen[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Expand replaces $var and ${var} with the value of the variable. Unknown variables expand to an
+// empty string. Text in single quotes and escaped dollar signs are left unchanged.
+func (env *Environment) Expand(input string) string {
+	return env.expand(input, false)
+}
+
+// expandLine expands the variables of a command line. The values are quoted so they are split into
+// the same args as the variable, e.g. a value with spaces or quotes stays a single arg.
+func (env *Environment) expandLine(input string) string {
+	return env.expand(input, true)
+}
+
+func (env *Environment) expand(input string, quote bool) string {
+	var (
+		buf     strings.Builder
+		single  bool
+		double  bool
+		escaped bool
+	)
+
+	for index := 0; index < len(input); index++ {
+		ch := input[index]
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\' && !single:
+			escaped = true
+		case ch == '\'' && !double:
+			single = !single
+		case ch == '"' && !single:
+			double = !double
+		case ch == '$' && !single:
+			if name, n := variableName(input[index+1:]); n > 0 {
+				value := FormatValue(env.Get(name))
+				switch {
+				case !quote || value == "":
+				case double:
+					value = escapeDoubleQuoted(value)
+				default:
+					value = shellquote.Join(value)
+				}
+				buf.WriteString(value)
+				index += n
+				continue
+			}
+		}
+		buf.WriteByte(ch)
+	}
+	return buf.String()
+}
+
+// escapeDoubleQuoted escapes the characters which are special in double quotes.
+func escapeDoubleQuoted(value string) string {
+	var buf strings.Builder
+	for index := 0; index < len(value); index++ {
+		if strings.IndexByte("\"\\$`", value[index]) >= 0 {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(value[index])
+	}
+	return buf.String()
+}
+
+// variableName returns the variable name at the start of the input and the number of bytes used.
+func variableName(input string) (string, int) {
+	if strings.HasPrefix(input, "{") {
+		end := strings.Index(input, "}")
+		if end <= 1 {
+			return "", 0
+		}
+		return input[1:end], end + 1
+	}
+
+	var n int
+	for n < len(input) && isVariableChar(input[n]) {
+		n++
+	}
+	return input[:n], n
+}
+
+func isVariableChar(ch byte) bool {
+	return ch == '_' || ch == '.' ||
+		('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9')
+}
+
+func addVariableCommands(scope *Scope) {
+	keySuggestions := func(env *Environment, args []string) []string {
+		if len(args) < 3 {
+			return env.Keys()
+		}
+		return []string{}
+	}
+
+	scope.AddCommand(&Command{
+		Use:   "env",
+		Short: "env lists all the environment variables for the commands",
+		Run: func(env *Environment, cmd *Command, args []string) error {
+			vars := map[string]interface{}{}
+			for _, key := range env.Keys() {
+				vars[key] = env.Get(key)
+
+				// durations are written in a readable form
+				if d, ok := vars[key].(time.Duration); ok {
+					vars[key] = d.String()
+				}
+			}
+			return env.Output(vars)
+		},
+		IsBuiltIn:       true,
+		ShouldPropagate: true,
+	})
+
+	scope.AddCommand(&Command{
+		Use:              "get",
+		Short:            "Gets a current env var",
+		EagerSuggestions: true,
+		Suggestions:      keySuggestions,
+		Run: func(env *Environment, cmd *Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("requires 1 argument")
+			}
+			fmt.Fprintf(env.Out, "%s   %v\n", args[0], FormatValue(env.Get(args[0])))
+			return nil
+		},
+		IsBuiltIn:       true,
+		ShouldPropagate: true,
+	})
+
+	set := &Command{
+		Use:              "set",
+		Short:            "Sets an env var",
+		Long:             "Local variables shadow variables of the same name and are removed when the scope is exited.",
+		EagerSuggestions: true,
+		Suggestions:      keySuggestions,
+		Run: func(env *Environment, cmd *Command, args []string) error {
+			typ, err := cmd.Flags().GetString("type")
+			if err != nil {
+				return err
+			}
+			local, err := cmd.Flags().GetBool("local")
+			if err != nil {
+				return err
+			}
+
+			if typ == "list" && len(args) >= 2 {
+				args = []string{args[0], strings.Join(args[1:], ",")}
+			} else if len(args) != 2 {
+				return errors.New("requires 2 arguments")
+			}
+
+			value, err := ParseValue(typ, args[1])
+			if err != nil {
+				return err
+			}
+
+			if local {
+				env.SetLocal(args[0], value)
+			} else {
+				env.Set(args[0], value)
+			}
+			return nil
+		},
+		IsBuiltIn:       true,
+		ShouldPropagate: true,
+	}
+	set.Flags().StringP("type", "t", "string", "Value type: "+strings.Join(VariableTypes, ", "))
+	set.Flags().BoolP("local", "l", false, "Sets the variable in the current scope only")
+	set.SetFlagSuggestions("type",
+		Suggestion{Text: "string"},
+		Suggestion{Text: "int", Description: "Integer"},
+		Suggestion{Text: "float", Description: "Floating point number"},
+		Suggestion{Text: "bool", Description: "true or false"},
+		Suggestion{Text: "duration", Description: "Duration, e.g. 1m30s"},
+		Suggestion{Text: "list", Description: "Comma separated values"},
+	)
+	scope.AddCommand(set)
+
+	scope.AddCommand(&Command{
+		Use:              "unset",
+		Short:            "Removes env vars",
+		EagerSuggestions: true,
+		Suggestions: func(env *Environment, args []string) []string {
+			return env.Keys()
+		},
+		Run: func(env *Environment, cmd *Command, args []string) error {
+			if len(args) == 0 {
+				return errors.New("requires at least 1 argument")
+			}
+
+			for _, key := range args {
+				if err := env.Unset(key); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		IsBuiltIn:       true,
+		ShouldPropagate: true,
+	})
+}
@@ -0,0 +1,77 @@
+package console
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kballard/go-shellquote"
+)
+
+func newVariableEnvironment() *Environment {
+	env := NewEnvironment("> ")
+	env.Set("name", "world")
+	env.Set("greeting", "hello world")
+	env.Set("quote", `say "hi"`)
+	return env
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"no variables", "echo hello", "echo hello"},
+		{"variable", "hello $name", "hello world"},
+		{"braces", "${name}s", "worlds"},
+		{"unknown", "a $missing b", "a  b"},
+		{"single quotes", "'$name'", "'$name'"},
+		{"double quotes", `"$name"`, `"world"`},
+		{"quote in double quotes", `"it's $name"`, `"it's world"`},
+		{"escaped dollar", `\$name`, `\$name`},
+		{"escaped backslash", `\\$name`, `\\world`},
+		{"trailing dollar", "cost $", "cost $"},
+		{"empty braces", "${}", "${}"},
+		{"unclosed braces", "${name", "${name"},
+		{"value with spaces", "$greeting", "hello world"},
+	}
+
+	env := newVariableEnvironment()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := env.Expand(tt.input); got != tt.want {
+				t.Errorf("Expand(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"variable", "echo $name", []string{"echo", "world"}},
+		{"value with spaces", "echo $greeting", []string{"echo", "hello world"}},
+		{"value with quotes", "echo $quote", []string{"echo", `say "hi"`}},
+		{"double quotes", `echo "$quote!"`, []string{"echo", `say "hi"!`}},
+		{"joined in double quotes", `echo "$name, $greeting"`, []string{"echo", "world, hello world"}},
+		{"single quotes", "echo '$greeting'", []string{"echo", "$greeting"}},
+		{"escaped dollar", `echo \$greeting`, []string{"echo", "$greeting"}},
+		{"unknown", "echo $missing x", []string{"echo", "x"}},
+	}
+
+	env := newVariableEnvironment()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := env.expandLine(tt.input)
+			got, err := shellquote.Split(line)
+			if err != nil {
+				t.Fatalf("expandLine(%q) = %q: %v", tt.input, line, err)
+			} else if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandLine(%q) = %q, want args %q", tt.input, line, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package console
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/c-bata/go-prompt"
+)
+
+// Commands of the vi normal mode. The insert commands switch to insert mode after they run and the
+// operators wait for one of the motions or themselves, e.g. dw or dd.
+const (
+	viCommands       = "hl0^$wbxXDaIAsSC"
+	viInsertCommands = "aIAsSC"
+	viOperators      = "dc"
+	viMotions        = "wb$0^"
+)
+
+// Inputs sent by the vi parser to the prompt. They are not sent by terminals.
+var (
+	viNormalInput = []byte("\x1b[vi-normal]")
+	viInsertInput = []byte("\x1b[vi-insert]")
+)
+
+// viCommandInput returns the input sent by the vi parser to run a normal mode command.
+func viCommandInput(command string) []byte {
+	return []byte("\x1b[vi-command]" + command)
+}
+
+// errNoKey is returned by the vi parser if the input read from the terminal has no key for the
+// prompt yet. The prompt ignores reads which fail.
+var errNoKey = errors.New("no key")
+
+// viParser splits the input of the terminal into keys and handles the vi normal mode. The prompt
+// only runs the bindings of an input if it matches the whole input, so keys typed quickly or pasted
+// are split before they are passed on. In insert mode the keys are passed on as is. In normal mode
+// the commands are sent as inputs bound by the keymap and other characters are dropped.
+//
+// The mode is switched by the parser, as the keys following a mode change are parsed before the
+// prompt handles the change. The keymap only shows the mode in the prompt.
+type viParser struct {
+	*inputQueue
+
+	// modes maps the input of the keys bound to vi-normal-mode and vi-insert-mode to the mode they
+	// switch to. sequences are the bound input sequences unknown to the prompt, e.g. alt-b, including
+	// the ASCIICodeBinds of the environment.
+	modes     map[string]bool
+	sequences map[string]bool
+
+	// input is read but not parsed yet and keys are parsed but not read by the prompt yet
+	input []byte
+	keys  [][]byte
+
+	// normal is set in normal mode. operator is an operator waiting for a motion, e.g. the d of dw.
+	normal   bool
+	operator byte
+}
+
+func newViParser(in *inputQueue, bindings *KeyBindings, binds []prompt.ASCIICodeBind) *viParser {
+	p := &viParser{
+		inputQueue: in,
+		modes:      map[string]bool{},
+		sequences:  map[string]bool{},
+	}
+	for _, bind := range binds {
+		p.sequences[string(bind.ASCIICode)] = true
+	}
+
+	for _, name := range bindings.Keys() {
+		key, sequences, err := keyCodes(name)
+		if err != nil {
+			continue
+		}
+		for _, sequence := range sequences {
+			p.sequences[string(sequence)] = true
+		}
+		if key != prompt.NotDefined {
+			sequences = append(sequences, inputSequences(key)...)
+		}
+
+		action, _ := bindings.Action(name)
+		if action == "vi-normal-mode" || action == "vi-insert-mode" {
+			for _, sequence := range sequences {
+				p.modes[string(sequence)] = action == "vi-normal-mode"
+			}
+		}
+	}
+	return p
+}
+
+// inputSequences returns the input sequences of a key known to the prompt.
+func inputSequences(key prompt.Key) [][]byte {
+	var sequences [][]byte
+	for _, code := range prompt.ASCIISequences {
+		if code.Key == key {
+			sequences = append(sequences, code.ASCIICode)
+		}
+	}
+	return sequences
+}
+
+// Read returns the next key. Inputs pushed by actions are passed on as is.
+func (p *viParser) Read() ([]byte, error) {
+	if len(p.keys) == 0 {
+		select {
+		case input := <-p.pending:
+			p.breakLine(input)
+			p.keys = append(p.keys, input)
+		default:
+			input, err := p.ConsoleParser.Read()
+			if err != nil {
+				return nil, err
+			}
+			p.input = append(p.input, input...)
+			p.parse()
+		}
+	}
+
+	if len(p.keys) == 0 {
+		return nil, errNoKey
+	}
+	key := p.keys[0]
+	p.keys = p.keys[1:]
+	return key, nil
+}
+
+// parse splits the input into keys. An incomplete character at the end is kept until the rest of it
+// is read.
+func (p *viParser) parse() {
+	for len(p.input) > 0 {
+		n := p.keyLength(p.input)
+		if n == 0 {
+			return
+		}
+		p.key(p.input[:n])
+		p.input = p.input[n:]
+	}
+}
+
+// keyLength returns the length of the key at the start of the input. Text typed in insert mode is
+// kept together so pasting is not slowed down by the prompt reading one key at a time.
+func (p *viParser) keyLength(input []byte) int {
+	if input[0] == 0x1b {
+		return p.sequenceLength(input)
+	} else if input[0] < 0x20 || input[0] == 0x7f {
+		return 1
+	}
+
+	var n int
+	for n < len(input) && input[n] >= 0x20 && input[n] != 0x7f {
+		if !utf8.FullRune(input[n:]) {
+			break
+		}
+		_, size := utf8.DecodeRune(input[n:])
+		n += size
+		if p.normal {
+			break
+		}
+	}
+	return n
+}
+
+// sequenceLength returns the length of the escape sequence at the start of the input. Escape
+// followed by a key which is not a known sequence is the escape key, e.g. escape and b typed
+// quickly.
+func (p *viParser) sequenceLength(input []byte) int {
+	for n := minInt(len(input), 8); n > 1; n-- {
+		if p.sequences[string(input[:n])] || prompt.GetKey(input[:n]) != prompt.NotDefined {
+			return n
+		}
+	}
+
+	// Unknown control sequences end with a character from @ to ~
+	if len(input) > 2 && input[1] == '[' {
+		for n := 2; n < len(input); n++ {
+			if input[n] >= 0x40 && input[n] <= 0x7e {
+				return n + 1
+			}
+		}
+		return len(input)
+	}
+	return 1
+}
+
+// key handles a key in the current mode.
+func (p *viParser) key(input []byte) {
+	if normal, ok := p.modes[string(input)]; ok {
+		p.setNormal(normal)
+		return
+	}
+
+	p.breakLine(input)
+	switch {
+	case !p.normal:
+		p.keys = append(p.keys, p.enter(input))
+	case len(input) == 1 && isPrintable(input[0]):
+		p.command(input[0])
+	case input[0] < 0x20 || input[0] == 0x7f || p.sequences[string(input)] ||
+		prompt.GetKey(input) != prompt.NotDefined:
+		p.operator = 0
+		p.keys = append(p.keys, p.enter(input))
+	}
+}
+
+// breakLine starts insert mode if the key breaks the line. The keymap is reset when the prompt
+// breaks the line.
+func (p *viParser) breakLine(input []byte) {
+	switch prompt.GetKey(input) {
+	case prompt.Enter, prompt.ControlJ, prompt.ControlM, prompt.ControlC:
+		p.normal, p.operator = false, 0
+	}
+}
+
+// command handles a character typed in normal mode.
+func (p *viParser) command(ch byte) {
+	if op := p.operator; op != 0 {
+		p.operator = 0
+		if ch == op || strings.IndexByte(viMotions, ch) >= 0 {
+			p.keys = append(p.keys, viCommandInput(string([]byte{op, ch})))
+			if op == 'c' {
+				p.setNormal(false)
+			}
+		}
+		return
+	}
+
+	switch {
+	case ch == 'k':
+		p.keys = append(p.keys, inputSequences(prompt.Up)[0])
+	case ch == 'j':
+		p.keys = append(p.keys, inputSequences(prompt.Down)[0])
+	case ch == 'i':
+		p.setNormal(false)
+	case strings.IndexByte(viOperators, ch) >= 0:
+		p.operator = ch
+	case strings.IndexByte(viCommands, ch) >= 0:
+		p.keys = append(p.keys, viCommandInput(string(ch)))
+		if strings.IndexByte(viInsertCommands, ch) >= 0 {
+			p.setNormal(false)
+		}
+	}
+}
+
+// setNormal switches the mode and tells the keymap.
+func (p *viParser) setNormal(normal bool) {
+	p.operator = 0
+	if p.normal == normal {
+		return
+	}
+
+	p.normal = normal
+	if normal {
+		p.keys = append(p.keys, viNormalInput)
+	} else {
+		p.keys = append(p.keys, viInsertInput)
+	}
+}